@@ -1,26 +1,59 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
-	"os"
 
 	"xgfile/pkg/xgfile"
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("Usage: xgfile <input_file>")
+	salvage := flag.Bool("recover", false, "salvage what can be read from a damaged file and report the damage")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		log.Fatal("Usage: xgfile [-recover] <input_file>")
 	}
 
-	inputFile := os.Args[1]
+	inputFile := flag.Arg(0)
 	importer := xgfile.NewImport(inputFile)
+	importer.Recover = *salvage
 
 	segments, err := importer.GetFileSegment()
 	if err != nil {
 		log.Fatalf("Error extracting file segments: %v", err)
 	}
 
+	for _, diagnostic := range importer.Diagnostics {
+		fmt.Printf("Damaged: %v\n", diagnostic)
+	}
+
+	if hdr := importer.GameHdr; hdr != nil {
+		fmt.Printf("Game header: version %d, %s vs %s, %d point match\n",
+			hdr.Version, hdr.Match.SPlayer1, hdr.Match.SPlayer2, hdr.Match.MatchLength)
+	}
+
+	if match, err := xgfile.ReadMatch(segments); err != nil {
+		fmt.Printf("Cannot read match: %v\n", err)
+	} else {
+		fmt.Printf("Match: %s vs %s, %d games\n", match.Player1, match.Player2, len(match.Games))
+	}
+
+	if *salvage {
+		for _, segment := range segments {
+			if segment.Type != xgfile.XG_GAMEFILE {
+				continue
+			}
+			match, diagnostics := segment.RecoverGameFile()
+			for _, diagnostic := range diagnostics {
+				fmt.Printf("Damaged: %v\n", diagnostic)
+			}
+			if match != nil {
+				fmt.Printf("Recovered %d games\n", len(match.Games))
+			}
+		}
+	}
+
 	for _, segment := range segments {
 		fmt.Printf("Extracted segment: %s\n", segment.Filename)
 		if err := segment.Close(); err != nil {
@@ -0,0 +1,311 @@
+package xgfile
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Comment is one annotation of the comment file, both as stored by XG and
+// rendered as plain text.
+type Comment struct {
+	RTF  string
+	Text string
+}
+
+// CommentFile holds every comment of a temp.xgc comment file, in the order
+// referenced by the Comment* indices of the game file records.
+type CommentFile struct {
+	Comments []Comment
+}
+
+// ReadCommentFile decodes all comments from the stream. Each comment is a
+// complete RTF document; anything between documents (line breaks, padding)
+// is ignored.
+func ReadCommentFile(stream io.Reader) (*CommentFile, error) {
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	cf := &CommentFile{}
+	depth := 0
+	start := 0
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			// Skip the escaped character so \{ and \} do not count.
+			i++
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				cause := errors.New("unbalanced brace")
+				return nil, withContext(newError(ErrCorrupt, int64(i), cause), "temp.xgc", 0, len(cf.Comments))
+			}
+			depth--
+			if depth == 0 {
+				rtf := string(data[start : i+1])
+				cf.Comments = append(cf.Comments, Comment{RTF: rtf, Text: RTFToText(rtf)})
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, withContext(newError(ErrTruncated, int64(start), nil), "temp.xgc", 0, len(cf.Comments))
+	}
+	return cf, nil
+}
+
+// CommentFile decodes the comments of an XG_COMMENT segment.
+func (s *Segment) CommentFile() (*CommentFile, error) {
+	if s.Type != XG_COMMENT {
+		return nil, errors.New("segment is not an XG comment file")
+	}
+	if _, err := s.Reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return ReadCommentFile(s.Reader)
+}
+
+// Comment returns the comment at the given index, or nil when the index
+// does not refer to a comment. Records use -1 for "no comment".
+func (cf *CommentFile) Comment(index int32) *Comment {
+	if index < 0 || int(index) >= len(cf.Comments) {
+		return nil
+	}
+	return &cf.Comments[index]
+}
+
+// MoveComment returns the comment attached to a checker play.
+func (cf *CommentFile) MoveComment(me *MoveEntry) *Comment {
+	return cf.Comment(me.CommentMove)
+}
+
+// CubeComment returns the comment attached to a cube action.
+func (cf *CommentFile) CubeComment(ce *CubeEntry) *Comment {
+	return cf.Comment(ce.CommentCube)
+}
+
+// GameHeaderComment returns the comment shown before a game.
+func (cf *CommentFile) GameHeaderComment(hge *HeaderGameEntry) *Comment {
+	return cf.Comment(hge.CommentHeaderGame)
+}
+
+// GameFooterComment returns the comment shown after a game.
+func (cf *CommentFile) GameFooterComment(hge *HeaderGameEntry) *Comment {
+	return cf.Comment(hge.CommentFooterGame)
+}
+
+// MatchHeaderComment returns the comment shown before the match.
+func (cf *CommentFile) MatchHeaderComment(hme *HeaderMatchEntry) *Comment {
+	return cf.Comment(hme.CommentHeaderMatch)
+}
+
+// MatchFooterComment returns the comment shown after the match.
+func (cf *CommentFile) MatchFooterComment(hme *HeaderMatchEntry) *Comment {
+	return cf.Comment(hme.CommentFooterMatch)
+}
+
+// rtfSkipDestinations are groups that hold formatting data rather than
+// text.
+var rtfSkipDestinations = map[string]bool{
+	"fonttbl":    true,
+	"colortbl":   true,
+	"stylesheet": true,
+	"info":       true,
+	"pict":       true,
+	"header":     true,
+	"footer":     true,
+	"generator":  true,
+	"listtable":  true,
+	"themedata":  true,
+}
+
+// cp1252 holds the characters of Windows code page 1252 for bytes 0x80
+// to 0x9F, where it differs from Latin-1. Bytes it leaves undefined keep
+// their Latin-1 value.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// RTF_DEFAULT_CODEPAGE is the code page of hex escapes when the document
+// does not set one with \ansicpg.
+const RTF_DEFAULT_CODEPAGE = 1252
+
+// ansiToRune decodes a byte of the given ANSI code page. Code pages other
+// than 1252 are read as Latin-1.
+func ansiToRune(b byte, codepage int) rune {
+	if codepage == 1252 && b >= 0x80 && b <= 0x9F {
+		return cp1252[b-0x80]
+	}
+	return rune(b)
+}
+
+// RTFToText renders an RTF document as plain text. Paragraph and line
+// breaks become newlines, hex and unicode escapes are decoded and all
+// formatting is dropped. Hex escapes are read in the code page set by
+// \ansicpg, Windows-1252 by default.
+func RTFToText(rtf string) string {
+	type groupState struct {
+		skip     bool
+		ucSkip   int
+		firstCmd bool
+	}
+
+	var out strings.Builder
+	state := groupState{ucSkip: 1}
+	var stack []groupState
+	pendingSkip := 0
+	codepage := RTF_DEFAULT_CODEPAGE
+
+	for i := 0; i < len(rtf); i++ {
+		c := rtf[i]
+		switch c {
+		case '{':
+			stack = append(stack, state)
+			state.firstCmd = true
+			continue
+		case '}':
+			if len(stack) > 0 {
+				state = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		case '\r', '\n':
+			continue
+		case '\\':
+		default:
+			state.firstCmd = false
+			if pendingSkip > 0 {
+				pendingSkip--
+				continue
+			}
+			if !state.skip {
+				out.WriteByte(c)
+			}
+			continue
+		}
+
+		// Control symbol or control word.
+		i++
+		if i >= len(rtf) {
+			break
+		}
+		c = rtf[i]
+		firstCmd := state.firstCmd
+		state.firstCmd = false
+		if !isASCIILetter(c) {
+			switch c {
+			case '\\', '{', '}':
+				if pendingSkip > 0 {
+					pendingSkip--
+				} else if !state.skip {
+					out.WriteByte(c)
+				}
+			case '~':
+				if !state.skip {
+					out.WriteRune(' ')
+				}
+			case '_':
+				if !state.skip {
+					out.WriteByte('-')
+				}
+			case '*':
+				state.skip = true
+			case '\'':
+				if i+2 < len(rtf) {
+					if v, err := strconv.ParseUint(rtf[i+1:i+3], 16, 8); err == nil {
+						if pendingSkip > 0 {
+							pendingSkip--
+						} else if !state.skip {
+							out.WriteRune(ansiToRune(byte(v), codepage))
+						}
+					}
+					i += 2
+				}
+			case '\r', '\n':
+				if !state.skip {
+					out.WriteByte('\n')
+				}
+			}
+			continue
+		}
+
+		start := i
+		for i < len(rtf) && isASCIILetter(rtf[i]) {
+			i++
+		}
+		word := rtf[start:i]
+		paramStart := i
+		if i < len(rtf) && rtf[i] == '-' {
+			i++
+		}
+		for i < len(rtf) && rtf[i] >= '0' && rtf[i] <= '9' {
+			i++
+		}
+		param, hasParam := 0, i > paramStart
+		if hasParam {
+			param, _ = strconv.Atoi(rtf[paramStart:i])
+		}
+		// A single space delimits the control word and is not text.
+		if i >= len(rtf) || rtf[i] != ' ' {
+			i--
+		}
+
+		if firstCmd && rtfSkipDestinations[word] {
+			state.skip = true
+			continue
+		}
+		if state.skip {
+			continue
+		}
+		switch word {
+		case "par", "line", "sect", "page":
+			out.WriteByte('\n')
+		case "tab":
+			out.WriteByte('\t')
+		case "emdash":
+			out.WriteRune('—')
+		case "endash":
+			out.WriteRune('–')
+		case "bullet":
+			out.WriteRune('•')
+		case "lquote":
+			out.WriteRune('‘')
+		case "rquote":
+			out.WriteRune('’')
+		case "ldblquote":
+			out.WriteRune('“')
+		case "rdblquote":
+			out.WriteRune('”')
+		case "ansicpg":
+			if hasParam {
+				codepage = param
+			}
+		case "uc":
+			if hasParam {
+				state.ucSkip = param
+			}
+		case "u":
+			if hasParam {
+				if param < 0 {
+					param += 65536
+				}
+				out.WriteRune(rune(param))
+				pendingSkip = state.ucSkip
+			}
+		}
+	}
+
+	return strings.TrimRightFunc(out.String(), unicode.IsSpace)
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,117 @@
+package xgfile
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRTFToTextHexEscapes(t *testing.T) {
+	for _, tc := range []struct {
+		rtf  string
+		want string
+	}{
+		{`{\rtf1\ansi\ansicpg1252 It\'92s \'93ok\'94 \'80 5\'85}`, "It’s “ok” € 5…"},
+		{`{\rtf1\ansi It\'92s \'e9t\'e9}`, "It’s été"},
+		{`{\rtf1\ansi\ansicpg1252 \'96\'97\'99\'9f}`, "–—™Ÿ"},
+		// Bytes left undefined by code page 1252 keep their value.
+		{`{\rtf1\ansi \'81\'8d}`, "\u0081\u008d"},
+		// Other code pages are read as Latin-1.
+		{`{\rtf1\ansi\ansicpg28591 \'92\'e9}`, "\u0092é"},
+	} {
+		if got := RTFToText(tc.rtf); got != tc.want {
+			t.Errorf("RTFToText(%q) = %q, want %q", tc.rtf, got, tc.want)
+		}
+	}
+}
+
+func TestReadCommentFile(t *testing.T) {
+	data := "{\\rtf1 First}\r\n\x00" +
+		"{\\rtf1{\\fonttbl{\\f0 Arial;}}\\f0 Nested {\\b bold} text}" +
+		"{\\rtf1 Escaped \\{ and \\} braces\\\\}" +
+		"{\\rtf1 Last\\par line}"
+	cf, err := ReadCommentFile(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Comment{
+		{RTF: "{\\rtf1 First}", Text: "First"},
+		{RTF: "{\\rtf1{\\fonttbl{\\f0 Arial;}}\\f0 Nested {\\b bold} text}", Text: "Nested bold text"},
+		{RTF: "{\\rtf1 Escaped \\{ and \\} braces\\\\}", Text: "Escaped { and } braces\\"},
+		{RTF: "{\\rtf1 Last\\par line}", Text: "Last\nline"},
+	}
+	if len(cf.Comments) != len(want) {
+		t.Fatalf("got %d comments %q, want %d", len(cf.Comments), cf.Comments, len(want))
+	}
+	for i, c := range cf.Comments {
+		if c != want[i] {
+			t.Errorf("comment %d = %q, want %q", i, c, want[i])
+		}
+	}
+}
+
+func TestReadCommentFileErrors(t *testing.T) {
+	for _, tc := range []struct {
+		data  string
+		want  error
+		index int
+	}{
+		{"{\\rtf1 One}}", ErrCorrupt, 1},
+		{"{\\rtf1 One}{\\rtf1 {Two}", ErrTruncated, 1},
+		{"{\\rtf1 One\\}", ErrTruncated, 0},
+	} {
+		_, err := ReadCommentFile(strings.NewReader(tc.data))
+		var xgErr *Error
+		if !errors.Is(err, tc.want) || !errors.As(err, &xgErr) || xgErr.Record != tc.index {
+			t.Errorf("ReadCommentFile(%q) = %v, want %v at comment %d", tc.data, err, tc.want, tc.index)
+		}
+	}
+}
+
+func TestRTFToTextUnicode(t *testing.T) {
+	for _, tc := range []struct {
+		rtf  string
+		want string
+	}{
+		// The character after \u is its ANSI fallback and is skipped.
+		{`{\rtf1 Caf\u233?}`, "Café"},
+		{`{\rtf1 \u8364?5}`, "€5"},
+		// Code points above 32767 are written as negative numbers.
+		{`{\rtf1 \u-3913?}`, "\uf0b7"},
+		// \uc sets the number of fallback characters, for its group.
+		{`{\rtf1\uc2 \u233\'65\'27x{\uc0 \u233 y}\u233??z}`, "éxéyéz"},
+		{`{\rtf1\uc1 \u8217\'92s}`, "’s"},
+		{`{\rtf1{\*\generator Riched20;}\pard Text\tab x\line y}`, "Text\tx\ny"},
+	} {
+		if got := RTFToText(tc.rtf); got != tc.want {
+			t.Errorf("RTFToText(%q) = %q, want %q", tc.rtf, got, tc.want)
+		}
+	}
+}
+
+func TestCommentFileLookup(t *testing.T) {
+	cf := &CommentFile{Comments: []Comment{{Text: "zero"}, {Text: "one"}, {Text: "two"}}}
+	for _, tc := range []struct {
+		got  *Comment
+		want string
+	}{
+		{cf.Comment(0), "zero"},
+		{cf.Comment(-1), ""},
+		{cf.Comment(3), ""},
+		{cf.MoveComment(&MoveEntry{CommentMove: 2}), "two"},
+		{cf.CubeComment(&CubeEntry{CommentCube: 1}), "one"},
+		{cf.CubeComment(&CubeEntry{CommentCube: -1}), ""},
+		{cf.GameHeaderComment(&HeaderGameEntry{CommentHeaderGame: 0, CommentFooterGame: 1}), "zero"},
+		{cf.GameFooterComment(&HeaderGameEntry{CommentHeaderGame: 0, CommentFooterGame: 1}), "one"},
+		{cf.MatchHeaderComment(&HeaderMatchEntry{CommentHeaderMatch: 2, CommentFooterMatch: -1}), "two"},
+		{cf.MatchFooterComment(&HeaderMatchEntry{CommentHeaderMatch: 2, CommentFooterMatch: -1}), ""},
+	} {
+		var got string
+		if tc.got != nil {
+			got = tc.got.Text
+		}
+		if got != tc.want {
+			t.Errorf("lookup gave %q, want %q", got, tc.want)
+		}
+	}
+}
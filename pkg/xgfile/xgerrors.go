@@ -0,0 +1,95 @@
+package xgfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Reasons an XG file is rejected. Errors returned while decoding a file
+// are *Error values wrapping one of these, so they can be tested with
+// errors.Is.
+var (
+	ErrBadMagic           = errors.New("bad magic number")
+	ErrUnsupportedVersion = errors.New("unsupported version")
+	ErrTruncated          = errors.New("truncated record")
+	ErrChecksum           = errors.New("checksum mismatch")
+	ErrUnknownEntryType   = errors.New("unknown entry type")
+	ErrDecompress         = errors.New("decompression failed")
+	ErrCorrupt            = errors.New("invalid size or offset")
+)
+
+// Error locates a decoding failure in an XG file. Err is one of the
+// sentinel errors above; Cause, if set, is the underlying error or a
+// description of the offending value.
+type Error struct {
+	Err error
+	// Segment names the part of the file: "GDF header", "archive",
+	// "archive registry" or the name of an archive entry such as
+	// "temp.xg".
+	Segment string
+	// Offset is the byte offset of the failure within the segment.
+	Offset int64
+	// Record is the index of the record within the segment, or -1 when
+	// the segment is not made of records.
+	Record int
+	Cause  error
+}
+
+func newError(kind error, offset int64, cause error) *Error {
+	return &Error{Err: kind, Offset: offset, Record: -1, Cause: cause}
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	if e.Segment != "" {
+		b.WriteString(e.Segment)
+		b.WriteByte(' ')
+	}
+	if e.Record >= 0 {
+		fmt.Fprintf(&b, "record %d ", e.Record)
+	}
+	fmt.Fprintf(&b, "at offset %d: %v", e.Offset, e.Err)
+	if e.Cause != nil {
+		fmt.Fprintf(&b, ": %v", e.Cause)
+	}
+	return b.String()
+}
+
+// Unwrap lets errors.Is and errors.As match both the sentinel and the
+// cause.
+func (e *Error) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.Cause}
+}
+
+// withContext places an error returned by a record decoder within its
+// segment: the segment name and record index are filled in and the
+// offset, relative to the record, is made relative to the segment. A
+// short read becomes ErrTruncated. io.EOF, errors that already carry a
+// segment and errors other than decoding failures are returned unchanged.
+func withContext(err error, segment string, offset int64, record int) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	var xerr *Error
+	if !errors.As(err, &xerr) {
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+		xerr = newError(ErrTruncated, 0, err)
+	}
+	if xerr.Segment != "" {
+		// Already placed by an inner reader.
+		return err
+	}
+	xerr.Segment = segment
+	xerr.Offset += offset
+	if xerr.Record < 0 {
+		xerr.Record = record
+	}
+	return xerr
+}
@@ -0,0 +1,167 @@
+package xgfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// Export writes an XG file from decoded records. It is the reverse of
+// Import.GetFileSegment: the GDF header, the optional thumbnail and a zlib
+// archive holding the temp.xgi, temp.xg, temp.xgr and temp.xgc segments.
+type Export struct {
+	// Header supplies the GUID, names and comments of the GDF header. The
+	// magic number, version, size and thumbnail fields are set on write.
+	Header GameDataFormatHdrRecord
+	// Thumbnail is a JPEG image stored after the header, or nil.
+	Thumbnail []byte
+	// Records are the game file records, starting with the match header.
+	Records []*GameFileRecord
+	// Rollouts and Comments are referenced by index from Records. A
+	// comment with an empty RTF is written as RTF_EMPTY_COMMENT.
+	Rollouts []RolloutContextEntry
+	Comments []Comment
+	// CompressionLevel is passed to ZlibArchiveWriter.AddFile for every
+	// segment.
+	CompressionLevel int
+}
+
+// RTF_EMPTY_COMMENT is written for a comment whose RTF is empty.
+const RTF_EMPTY_COMMENT = `{\rtf1 }`
+
+func NewExport() *Export {
+	return &Export{CompressionLevel: 6}
+}
+
+// WriteFile writes the XG file to filename, replacing any existing file.
+func (exp *Export) WriteFile(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := exp.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteTo writes the XG file to w and returns the number of bytes written.
+func (exp *Export) WriteTo(w io.Writer) (int64, error) {
+	gameHdr, gameFile, err := exp.gameFileSegments()
+	if err != nil {
+		return 0, err
+	}
+
+	var rollouts bytes.Buffer
+	for i := range exp.Rollouts {
+		packedData, err := exp.Rollouts[i].MarshalBinary()
+		if err != nil {
+			return 0, err
+		}
+		rollouts.Write(packedData)
+	}
+
+	var comments bytes.Buffer
+	for i, comment := range exp.Comments {
+		if i > 0 {
+			comments.WriteString("\r\n")
+		}
+		// Comments are told apart by their RTF groups, so an empty one
+		// still needs a group to keep the indices of those after it.
+		if comment.RTF == "" {
+			comments.WriteString(RTF_EMPTY_COMMENT)
+		} else {
+			comments.WriteString(comment.RTF)
+		}
+	}
+
+	if exp.Thumbnail != nil && !bytes.HasPrefix(exp.Thumbnail, []byte{0xff, 0xd8}) {
+		return 0, errors.New("thumbnail is not a JPEG image")
+	}
+
+	hdr := exp.Header
+	hdr.HeaderVersion = 1
+	hdr.HeaderSize = GDF_HDR_LEN
+	hdr.ThumbnailOffset = 0
+	hdr.ThumbnailSize = int32(len(exp.Thumbnail))
+	if hdr.ThumbnailSize > 0 {
+		hdr.ThumbnailOffset = GDF_HDR_LEN
+	}
+	packedHdr, err := hdr.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+
+	cw := &countingWriter{w: w}
+	if _, err := cw.Write(packedHdr); err != nil {
+		return cw.n, err
+	}
+	if _, err := cw.Write(exp.Thumbnail); err != nil {
+		return cw.n, err
+	}
+
+	zw := NewZlibArchiveWriter(cw)
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{
+		{"temp.xgi", gameHdr},
+		{"temp.xg", gameFile},
+		{"temp.xgr", rollouts.Bytes()},
+		{"temp.xgc", comments.Bytes()},
+	} {
+		if err := zw.AddFile(entry.name, entry.data, exp.CompressionLevel); err != nil {
+			return cw.n, err
+		}
+	}
+	err = zw.Close()
+	return cw.n, err
+}
+
+// gameFileSegments encodes the temp.xgi and temp.xg segments. Records are
+// encoded with the version of the preceding match header, as they are
+// decoded by GameFileReader.
+func (exp *Export) gameFileSegments() ([]byte, []byte, error) {
+	if len(exp.Records) == 0 {
+		return nil, nil, errors.New("no game file records to export")
+	}
+	hme, ok := exp.Records[0].Record.(*HeaderMatchEntry)
+	if !ok {
+		return nil, nil, errors.New("first game file record is not a match header")
+	}
+
+	ghr := GameHdrRecord{Match: *hme}
+	gameHdr, err := ghr.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var gameFile bytes.Buffer
+	version := hme.Version
+	for _, gfr := range exp.Records {
+		if hme, ok := gfr.Record.(*HeaderMatchEntry); ok {
+			version = hme.Version
+		}
+		rec := GameFileRecord{Record: gfr.Record, Version: version}
+		packedData, err := rec.MarshalBinary()
+		if err != nil {
+			return nil, nil, err
+		}
+		gameFile.Write(packedData)
+	}
+
+	return gameHdr, gameFile.Bytes(), nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,54 @@
+package xgfile
+
+import (
+	"bytes"
+	"testing"
+)
+
+// An empty comment keeps its index, so the comments after it stay
+// attached to their records.
+func TestExportEmptyComment(t *testing.T) {
+	exp := NewExport()
+	exp.Records = testMatchRecords(28)
+	exp.Records[2].Record.(*CubeEntry).CommentCube = 2
+	exp.Records[3].Record.(*MoveEntry).CommentMove = 1
+	exp.Comments = []Comment{
+		{RTF: `{\rtf1\ansi First}`},
+		{},
+		{RTF: `{\rtf1\ansi Third}`},
+	}
+	var buf bytes.Buffer
+	if _, err := exp.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	imp := NewImportReaderAt(bytes.NewReader(data), int64(len(data)))
+	imp.InMemory = true
+	segments, err := imp.GetFileSegment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cf *CommentFile
+	var records []*GameFileRecord
+	for _, segment := range segments {
+		switch segment.Type {
+		case XG_COMMENT:
+			cf, err = segment.CommentFile()
+		case XG_GAMEFILE:
+			records, err = readGameFileRecords(segment)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cf == nil || len(cf.Comments) != 3 {
+		t.Fatalf("comment file %+v, want 3 comments", cf)
+	}
+	if c := cf.CubeComment(records[2].Record.(*CubeEntry)); c == nil || c.Text != "Third" {
+		t.Errorf("cube comment %+v, want Third", c)
+	}
+	if c := cf.MoveComment(records[3].Record.(*MoveEntry)); c == nil || c.RTF != RTF_EMPTY_COMMENT || c.Text != "" {
+		t.Errorf("move comment %+v, want the empty comment", c)
+	}
+}
@@ -0,0 +1,155 @@
+package xgfile
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// Seed corpora are built from files written by Export, stored and
+// compressed, for the oldest and newest record versions.
+
+func fuzzSeedFiles(f *testing.F) [][]byte {
+	var files [][]byte
+	for _, version := range []int32{0, 28} {
+		for _, level := range []int{0, 6} {
+			files = append(files, testExportBytes(f, version, level))
+		}
+	}
+	return files
+}
+
+// fuzzSeedSegments returns the segments of type segmentType of the seed
+// files.
+func fuzzSeedSegments(f *testing.F, segmentType int) [][]byte {
+	var segments [][]byte
+	for _, data := range fuzzSeedFiles(f) {
+		imp := NewImportReaderAt(bytes.NewReader(data), int64(len(data)))
+		imp.InMemory = true
+		imported, err := imp.GetFileSegment()
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, segment := range imported {
+			if segment.Type == segmentType {
+				segments = append(segments, testSegmentBytes(f, segment))
+			}
+		}
+	}
+	return segments
+}
+
+func FuzzGameDataFormatHdrRecord(f *testing.F) {
+	for _, data := range fuzzSeedFiles(f) {
+		f.Add(data[:GDF_HDR_LEN])
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		hdr := &GameDataFormatHdrRecord{}
+		if err := hdr.FromStream(bytes.NewReader(data)); err != nil {
+			return
+		}
+		packedData, err := hdr.MarshalBinary()
+		if err != nil {
+			t.Fatalf("encode decoded header: %v", err)
+		}
+		again := &GameDataFormatHdrRecord{}
+		if err := again.FromStream(bytes.NewReader(packedData)); err != nil {
+			t.Fatalf("decode encoded header: %v", err)
+		}
+		if *again != *hdr {
+			t.Fatalf("header changed in a round trip:\n%+v\n%+v", hdr, again)
+		}
+	})
+}
+
+func FuzzArchiveIndex(f *testing.F) {
+	for _, data := range fuzzSeedFiles(f) {
+		f.Add(data)
+		f.Add(data[GDF_HDR_LEN:])
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		za, err := newZlibArchive(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for _, filerec := range za.ArcRegistry {
+			reader, err := za.OpenArchiveFile(filerec)
+			if err != nil {
+				continue
+			}
+			if _, err := io.Copy(io.Discard, reader); err != nil {
+				t.Fatalf("read %s: %v", filerec.Name, err)
+			}
+		}
+	})
+}
+
+func FuzzFileRecord(f *testing.F) {
+	for _, data := range fuzzSeedFiles(f) {
+		za, err := newZlibArchive(bytes.NewReader(data))
+		if err != nil {
+			f.Fatal(err)
+		}
+		for i := range za.ArcRegistry {
+			packedData, err := za.ArcRegistry[i].MarshalBinary()
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(packedData)
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fr := &FileRecord{}
+		if err := fr.FromStream(bytes.NewReader(data)); err != nil {
+			return
+		}
+		packedData, err := fr.MarshalBinary()
+		if err != nil {
+			t.Fatalf("encode decoded record: %v", err)
+		}
+		again := &FileRecord{}
+		if err := again.FromStream(bytes.NewReader(packedData)); err != nil {
+			t.Fatalf("decode encoded record: %v", err)
+		}
+		if *again != *fr {
+			t.Fatalf("record changed in a round trip:\n%+v\n%+v", fr, again)
+		}
+	})
+}
+
+func FuzzGameFileRecord(f *testing.F) {
+	for _, gameFile := range fuzzSeedSegments(f, XG_GAMEFILE) {
+		version := int32(-1)
+		for start := 0; start+XG_GAMEFILEREC_LEN <= len(gameFile); start += XG_GAMEFILEREC_LEN {
+			record := gameFile[start : start+XG_GAMEFILEREC_LEN]
+			f.Add(record, version)
+			gfr := &GameFileRecord{Version: version}
+			if err := gfr.FromStream(bytes.NewReader(record)); err != nil {
+				f.Fatal(err)
+			}
+			version = gfr.Version
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte, version int32) {
+		gfr := &GameFileRecord{Version: version}
+		if err := gfr.FromStream(bytes.NewReader(data)); err != nil {
+			return
+		}
+		// Decoded values may hold NaNs, so the encodings are compared.
+		packedData, err := gfr.MarshalBinary()
+		if err != nil {
+			return
+		}
+		again := &GameFileRecord{Version: gfr.Version}
+		if err := again.FromStream(bytes.NewReader(packedData)); err != nil {
+			t.Fatalf("decode encoded record: %v", err)
+		}
+		repacked, err := again.MarshalBinary()
+		if err != nil {
+			t.Fatalf("encode record again: %v", err)
+		}
+		if !bytes.Equal(repacked, packedData) {
+			t.Fatalf("encoding changed in a round trip at byte %d", firstDifference(repacked, packedData))
+		}
+	})
+}
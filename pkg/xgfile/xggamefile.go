@@ -0,0 +1,84 @@
+package xgfile
+
+import (
+	"errors"
+	"io"
+)
+
+// GameFileReader iterates over the records of a temp.xg game file
+// segment.
+type GameFileReader struct {
+	stream  io.Reader
+	version int32
+	index   int
+}
+
+// NewGameFileReader checks the "DMLI" magic of the game file and returns
+// a reader on its first record, the HeaderMatchEntry. The game file has no
+// prefix to skip: the magic found XG_GAMEHDR_LEN bytes in is the Magic
+// field of that first record, so the reader starts at offset 0.
+func NewGameFileReader(stream io.ReadSeeker) (*GameFileReader, error) {
+	if err := checkGameFileMagic(stream); err != nil {
+		return nil, err
+	}
+	// checkGameFileMagic leaves the stream after the magic.
+	if _, err := stream.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return &GameFileReader{stream: stream, version: -1}, nil
+}
+
+// Next returns the next record of the game file, or io.EOF once every
+// record has been read. Decoding errors are *Error values locating the
+// record; the record is consumed, so Next may be called again to skip it.
+func (gr *GameFileReader) Next() (*GameFileRecord, error) {
+	index := gr.index
+	gr.index++
+	gfr := &GameFileRecord{Version: gr.version}
+	if err := gfr.FromStream(gr.stream); err != nil {
+		return nil, withContext(err, "temp.xg", int64(index)*XG_GAMEFILEREC_LEN, index)
+	}
+	gr.version = gfr.Version
+	return gfr, nil
+}
+
+// GameFileReader returns a reader over the records of an XG_GAMEFILE
+// segment.
+func (s *Segment) GameFileReader() (*GameFileReader, error) {
+	if s.Type != XG_GAMEFILE {
+		return nil, errors.New("segment is not an XG gamefile")
+	}
+	return NewGameFileReader(s.Reader)
+}
+
+// GameHdr decodes an XG_GAMEHDR segment.
+func (s *Segment) GameHdr() (*GameHdrRecord, error) {
+	if s.Type != XG_GAMEHDR {
+		return nil, errors.New("segment is not an XG game header")
+	}
+	if _, err := s.Reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	ghr := &GameHdrRecord{}
+	if err := ghr.FromStream(s.Reader); err != nil {
+		return nil, withContext(err, "temp.xgi", 0, -1)
+	}
+	return ghr, nil
+}
+
+func checkGameFileMagic(stream io.ReadSeeker) error {
+	if _, err := stream.Seek(XG_GAMEHDR_LEN, io.SeekStart); err != nil {
+		return err
+	}
+	magicStr := make([]byte, 4)
+	if _, err := io.ReadFull(stream, magicStr); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return withContext(err, "temp.xg", XG_GAMEHDR_LEN, -1)
+	}
+	if string(magicStr) != "DMLI" {
+		return withContext(newError(ErrBadMagic, 0, nil), "temp.xg", XG_GAMEHDR_LEN, -1)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package xgfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// The game file has no prefix before its records: the first record is
+// the match header holding the magic.
+func TestGameFileReaderFirstRecord(t *testing.T) {
+	data := testExportBytes(t, 28, 6)
+	imp := NewImportReaderAt(bytes.NewReader(data), int64(len(data)))
+	imp.InMemory = true
+	segments, err := imp.GetFileSegment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gr *GameFileReader
+	for _, segment := range segments {
+		if segment.Type == XG_GAMEFILE {
+			if gr, err = segment.GameFileReader(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if gr == nil {
+		t.Fatal("no game file segment")
+	}
+
+	gfr, err := gr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hme, ok := gfr.Record.(*HeaderMatchEntry)
+	if !ok {
+		t.Fatalf("first record is %T, want *HeaderMatchEntry", gfr.Record)
+	}
+	if hme.Player1 != "Alice" || hme.Player2 != "Bob" || gfr.Version != 28 {
+		t.Errorf("match header %q vs %q, version %d", hme.Player1, hme.Player2, gfr.Version)
+	}
+
+	want := testMatchRecords(28)
+	for i := 1; ; i++ {
+		gfr, err := gr.Next()
+		if err == io.EOF {
+			if i != len(want) {
+				t.Errorf("read %d records, want %d", i, len(want))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i < len(want) && reflect.TypeOf(gfr.Record) != reflect.TypeOf(want[i].Record) {
+			t.Errorf("record %d is %T, want %T", i, gfr.Record, want[i].Record)
+		}
+	}
+}
+
+func TestGameFileReaderBadMagic(t *testing.T) {
+	data := make([]byte, XG_GAMEFILEREC_LEN)
+	copy(data[XG_GAMEHDR_LEN:], "XXXX")
+	if _, err := NewGameFileReader(bytes.NewReader(data)); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("NewGameFileReader = %v, want ErrBadMagic", err)
+	}
+}
@@ -0,0 +1,231 @@
+package xgfile
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// GnuBG identifies positions with a Position ID, the base64 encoding of
+// an 80 bit key, and the rest of the match state with a Match ID, the
+// base64 encoding of a 66 bit key. Both are written without padding.
+
+// GNUBG_POSITIONKEY_LEN and GNUBG_MATCHKEY_LEN are the sizes of the keys
+// in bytes.
+const (
+	GNUBG_POSITIONKEY_LEN = 10
+	GNUBG_MATCHKEY_LEN    = 9
+)
+
+// PositionID returns the GnuBG Position ID of a position seen from the
+// player on roll. The key lists the opponent's checkers then the player's,
+// each from their own ace point to their bar, a checker being a 1 bit and
+// each point ending with a 0 bit.
+func PositionID(p Position) string {
+	var key [GNUBG_POSITIONKEY_LEN]byte
+	bit := 0
+	for _, side := range [2]Position{p.Flip(), p} {
+		for point := 1; point <= POS_BAR; point++ {
+			for n := int(side[point]); n > 0 && bit < 8*len(key); n-- {
+				key[bit/8] |= 1 << (bit % 8)
+				bit++
+			}
+			bit++
+		}
+	}
+	return base64.RawStdEncoding.EncodeToString(key[:])
+}
+
+// ParsePositionID decodes a GnuBG Position ID into a position seen from
+// the player on roll.
+func ParsePositionID(id string) (Position, error) {
+	key, err := base64.RawStdEncoding.DecodeString(id)
+	if err != nil {
+		return Position{}, fmt.Errorf("position ID: %v", err)
+	}
+	if len(key) != GNUBG_POSITIONKEY_LEN {
+		return Position{}, fmt.Errorf("position ID holds %d bytes, want %d", len(key), GNUBG_POSITIONKEY_LEN)
+	}
+
+	var sides [2]Position
+	bit := 0
+	for s := range sides {
+		for point := 1; point <= POS_BAR; point++ {
+			for ; bit < 8*len(key) && key[bit/8]&(1<<(bit%8)) != 0; bit++ {
+				sides[s][point]++
+			}
+			bit++
+		}
+	}
+	// The opponent's checkers are negative once seen from the player.
+	opponent := sides[0].Flip()
+	p := sides[1]
+	for point, n := range opponent {
+		if n != 0 && p[point] != 0 {
+			return Position{}, fmt.Errorf("position ID puts both sides on point %d", point)
+		}
+		p[point] += n
+	}
+	if err := p.Validate(); err != nil {
+		return Position{}, fmt.Errorf("position ID: %v", err)
+	}
+	return p, nil
+}
+
+// Game states of MatchID.GameState.
+const (
+	GNUBG_GAME_NONE = iota
+	GNUBG_GAME_PLAYING
+	GNUBG_GAME_OVER
+	GNUBG_GAME_RESIGNED
+	GNUBG_GAME_DROP
+)
+
+// GNUBG_CUBE_CENTERED is the MatchID.CubeOwner of a centered cube.
+const GNUBG_CUBE_CENTERED = 3
+
+// MatchID is the match state encoded by a GnuBG Match ID. Players are
+// numbered 0 and 1 as in GnuBG.
+type MatchID struct {
+	// CubeValue is the log2 of the cube value.
+	CubeValue int
+	CubeOwner int
+	// DiceOwner is the player on roll and Turn the player to make the
+	// next decision; they differ when a double or resignation has been
+	// offered.
+	DiceOwner int
+	Crawford  bool
+	GameState int
+	Turn      int
+	Doubled   bool
+	// Resigned is 0, or 1, 2 or 3 for a single, gammon or backgammon
+	// resignation.
+	Resigned int
+	// Dice are zero when the player on roll has not rolled.
+	Dice [2]int
+	// MatchLength is 0 for money play.
+	MatchLength int
+	Score       [2]int
+}
+
+// matchIDFields gives the bit offset and width of each field of the key,
+// in the order of the MatchID fields.
+var matchIDFields = [...]struct{ offset, width int }{
+	{0, 4}, {4, 2}, {6, 1}, {7, 1}, {8, 3}, {11, 1}, {12, 1}, {13, 2},
+	{15, 3}, {18, 3}, {21, 15}, {36, 15}, {51, 15},
+}
+
+func (m *MatchID) fields() [len(matchIDFields)]*int {
+	var crawford, doubled int
+	if m.Crawford {
+		crawford = 1
+	}
+	if m.Doubled {
+		doubled = 1
+	}
+	return [...]*int{
+		&m.CubeValue, &m.CubeOwner, &m.DiceOwner, &crawford, &m.GameState, &m.Turn,
+		&doubled, &m.Resigned, &m.Dice[0], &m.Dice[1], &m.MatchLength, &m.Score[0], &m.Score[1],
+	}
+}
+
+// String returns the Match ID.
+func (m *MatchID) String() string {
+	var key [GNUBG_MATCHKEY_LEN]byte
+	for i, v := range m.fields() {
+		f := matchIDFields[i]
+		for b := 0; b < f.width; b++ {
+			if *v&(1<<b) != 0 {
+				key[(f.offset+b)/8] |= 1 << ((f.offset + b) % 8)
+			}
+		}
+	}
+	return base64.RawStdEncoding.EncodeToString(key[:])
+}
+
+// ParseMatchID decodes a GnuBG Match ID.
+func ParseMatchID(id string) (*MatchID, error) {
+	key, err := base64.RawStdEncoding.DecodeString(id)
+	if err != nil {
+		return nil, fmt.Errorf("match ID: %v", err)
+	}
+	if len(key) != GNUBG_MATCHKEY_LEN {
+		return nil, fmt.Errorf("match ID holds %d bytes, want %d", len(key), GNUBG_MATCHKEY_LEN)
+	}
+
+	m := &MatchID{}
+	var values [len(matchIDFields)]int
+	for i, f := range matchIDFields {
+		for b := 0; b < f.width; b++ {
+			if key[(f.offset+b)/8]&(1<<((f.offset+b)%8)) != 0 {
+				values[i] |= 1 << b
+			}
+		}
+	}
+	for i, v := range m.fields() {
+		*v = values[i]
+	}
+	m.Crawford = values[3] != 0
+	m.Doubled = values[6] != 0
+
+	if m.CubeOwner == 2 {
+		return nil, fmt.Errorf("match ID cube owner %d invalid", m.CubeOwner)
+	}
+	if m.GameState > GNUBG_GAME_DROP {
+		return nil, fmt.Errorf("match ID game state %d invalid", m.GameState)
+	}
+	if m.Dice[0] > 6 || m.Dice[1] > 6 || (m.Dice[0] == 0) != (m.Dice[1] == 0) {
+		return nil, fmt.Errorf("match ID dice %d%d invalid", m.Dice[0], m.Dice[1])
+	}
+	return m, nil
+}
+
+// gnubgPlayer converts a player of an XGID, 1 or -1, to a GnuBG player:
+// XG's player 1 is GnuBG's player 0.
+func gnubgPlayer(player int32) int {
+	if player < 0 {
+		return 1
+	}
+	return 0
+}
+
+// PositionID returns the GnuBG Position ID of the XGID's position.
+func (x *XGID) PositionID() string {
+	return PositionID(x.Position.Perspective(1, x.Turn))
+}
+
+// MatchID returns the GnuBG match state of the XGID. XG's player 1 is
+// GnuBG's player 0.
+func (x *XGID) MatchID() *MatchID {
+	m := &MatchID{
+		CubeValue:   int(x.cubeLog2()),
+		CubeOwner:   GNUBG_CUBE_CENTERED,
+		DiceOwner:   gnubgPlayer(x.Turn),
+		Crawford:    x.MatchLength > 0 && x.CrawfordJacoby&1 != 0,
+		GameState:   GNUBG_GAME_PLAYING,
+		Turn:        gnubgPlayer(x.Turn),
+		Doubled:     x.CubeAction != 0,
+		Dice:        [2]int{int(x.Dice[0]), int(x.Dice[1])},
+		MatchLength: int(x.MatchLength),
+		Score:       [2]int{int(x.Score[0]), int(x.Score[1])},
+	}
+	if x.CubeOwner != 0 {
+		m.CubeOwner = gnubgPlayer(x.CubeOwner)
+	}
+	if m.Doubled {
+		// The opponent of the doubler answers.
+		m.Turn = 1 - m.DiceOwner
+	}
+	return m
+}
+
+// PositionID returns the GnuBG Position ID of the position before a
+// checker play.
+func (me *MoveEntry) PositionID() string {
+	return PositionID(me.StartPosition())
+}
+
+// PositionID returns the GnuBG Position ID of the position of a cube
+// decision.
+func (ce *CubeEntry) PositionID() string {
+	return PositionID(ce.CubePosition())
+}
@@ -0,0 +1,210 @@
+package xgfile
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// testGnubgKey builds a base64 key of the given size from bits written
+// least significant first, as in the GnuBG manual. Spaces are ignored.
+func testGnubgKey(size int, bits string) string {
+	key := make([]byte, size)
+	for i, c := range strings.ReplaceAll(bits, " ", "") {
+		if c == '1' {
+			key[i/8] |= 1 << (i % 8)
+		}
+	}
+	return base64.RawStdEncoding.EncodeToString(key)
+}
+
+// testPositionKey builds a Position ID from the checkers of each side on
+// its points 1 to 24 and its bar, numbered from that side.
+func testPositionKey(opponent, player map[int]int) string {
+	var bits strings.Builder
+	for _, side := range []map[int]int{opponent, player} {
+		for point := 1; point <= 25; point++ {
+			bits.WriteString(strings.Repeat("1", side[point]))
+			bits.WriteString("0")
+		}
+	}
+	return testGnubgKey(GNUBG_POSITIONKEY_LEN, bits.String())
+}
+
+func TestPositionID(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		pos  Position
+		id   string
+	}{
+		{"start", StartingPosition, "4HPwATDgc/ABMA"},
+		{"start from the key", StartingPosition, testPositionKey(
+			map[int]int{6: 5, 8: 3, 13: 5, 24: 2},
+			map[int]int{6: 5, 8: 3, 13: 5, 24: 2},
+		)},
+		{"race", testRacePosition, testPositionKey(
+			map[int]int{2: 3, 5: 5, 23: 1, 25: 2},
+			map[int]int{1: 2, 3: 3, 6: 4, 24: 1, 25: 1},
+		)},
+		{"empty", Position{}, "AAAAAAAAAAAAAA"},
+	} {
+		if got := PositionID(tc.pos); got != tc.id {
+			t.Errorf("%s: PositionID = %q, want %q", tc.name, got, tc.id)
+		}
+		got, err := ParsePositionID(tc.id)
+		if err != nil {
+			t.Errorf("%s: ParsePositionID(%q): %v", tc.name, tc.id, err)
+		} else if got != tc.pos {
+			t.Errorf("%s: ParsePositionID(%q) = %v, want %v", tc.name, tc.id, got, tc.pos)
+		}
+	}
+}
+
+func TestParsePositionIDErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		id   string
+	}{
+		{"not base64", "4HPwATDgc/AB!A"},
+		{"padded", "4HPwATDgc/ABMA=="},
+		{"short", "4HPwATDgc/AB"},
+		{"long", "4HPwATDgc/ABMAAA"},
+		// The opponent's 24 point is the player's 1 point.
+		{"both sides on one point", testPositionKey(map[int]int{24: 1}, map[int]int{1: 1})},
+		{"16 player checkers", testPositionKey(nil, map[int]int{6: 16})},
+		{"16 opponent checkers", testPositionKey(map[int]int{6: 10, 8: 6}, nil)},
+		{"16 checkers with the bar", testPositionKey(map[int]int{25: 2, 6: 14}, nil)},
+	} {
+		if p, err := ParsePositionID(tc.id); err == nil {
+			t.Errorf("%s: ParsePositionID(%q) = %v, want an error", tc.name, tc.id, p)
+		}
+	}
+}
+
+func TestMatchID(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		id   string
+		want MatchID
+	}{
+		// The example of the GnuBG manual: player 1 on roll with 52, a 2
+		// cube owned by player 0, 2-4 in a 9 point match.
+		{"manual", "QYkqASAAIAAA", MatchID{
+			CubeValue: 1, CubeOwner: 0, DiceOwner: 1, GameState: GNUBG_GAME_PLAYING, Turn: 1,
+			Dice: [2]int{5, 2}, MatchLength: 9, Score: [2]int{2, 4},
+		}},
+		{"manual bits", testGnubgKey(GNUBG_MATCHKEY_LEN,
+			"1000 00 1 0 100 1 0 00 101 010 100100000000000 010000000000000 001000000000000"), MatchID{
+			CubeValue: 1, CubeOwner: 0, DiceOwner: 1, GameState: GNUBG_GAME_PLAYING, Turn: 1,
+			Dice: [2]int{5, 2}, MatchLength: 9, Score: [2]int{2, 4},
+		}},
+		// The score order is kept: player 0's score comes first.
+		{"score order", testGnubgKey(GNUBG_MATCHKEY_LEN,
+			"1000 00 1 0 100 1 0 00 101 010 100100000000000 001000000000000 010000000000000"), MatchID{
+			CubeValue: 1, CubeOwner: 0, DiceOwner: 1, GameState: GNUBG_GAME_PLAYING, Turn: 1,
+			Dice: [2]int{5, 2}, MatchLength: 9, Score: [2]int{4, 2},
+		}},
+		// Money game before the first roll, cube centered.
+		{"money", "cAkAAAAAAAAA", MatchID{
+			CubeOwner: GNUBG_CUBE_CENTERED, DiceOwner: 1, GameState: GNUBG_GAME_PLAYING, Turn: 1,
+		}},
+		// Crawford game of a 5 point match at 4-2, player 0 on roll.
+		{"crawford", testGnubgKey(GNUBG_MATCHKEY_LEN,
+			"0000 11 0 1 100 0 0 00 110 100 101000000000000 001000000000000 010000000000000"), MatchID{
+			CubeOwner: GNUBG_CUBE_CENTERED, Crawford: true, GameState: GNUBG_GAME_PLAYING,
+			Dice: [2]int{3, 1}, MatchLength: 5, Score: [2]int{4, 2},
+		}},
+		// Player 0 owns a 4 cube and redoubles to 8; player 1 answers.
+		{"doubled", testGnubgKey(GNUBG_MATCHKEY_LEN,
+			"0100 00 0 0 100 1 1 00 000 000 111000000000000 000000000000000 010000000000000"), MatchID{
+			CubeValue: 2, CubeOwner: 0, GameState: GNUBG_GAME_PLAYING, Turn: 1, Doubled: true,
+			MatchLength: 7, Score: [2]int{0, 2},
+		}},
+		// Player 1 offers a gammon resignation in money play.
+		{"resigned", testGnubgKey(GNUBG_MATCHKEY_LEN,
+			"1000 10 1 0 100 0 0 01 000 000 000000000000000 000000000000000 000000000000000"), MatchID{
+			CubeValue: 1, CubeOwner: 1, DiceOwner: 1, GameState: GNUBG_GAME_PLAYING, Resigned: 2,
+		}},
+	} {
+		got, err := ParseMatchID(tc.id)
+		if err != nil {
+			t.Errorf("%s: ParseMatchID(%q): %v", tc.name, tc.id, err)
+			continue
+		}
+		if *got != tc.want {
+			t.Errorf("%s: ParseMatchID(%q) = %+v, want %+v", tc.name, tc.id, *got, tc.want)
+		}
+		if s := tc.want.String(); s != tc.id {
+			t.Errorf("%s: String() = %q, want %q", tc.name, s, tc.id)
+		}
+	}
+}
+
+func TestParseMatchIDErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		id   string
+	}{
+		{"not base64", "QYkqASAAIA!A"},
+		{"short", "QYkqASAA"},
+		{"long", "QYkqASAAIAAAAA"},
+		{"cube owner", testGnubgKey(GNUBG_MATCHKEY_LEN, "0000 01 0 0 100")},
+		{"game state", testGnubgKey(GNUBG_MATCHKEY_LEN, "0000 11 0 0 101")},
+		{"die above 6", testGnubgKey(GNUBG_MATCHKEY_LEN, "0000 11 0 0 100 0 0 00 111 100")},
+		{"one die", testGnubgKey(GNUBG_MATCHKEY_LEN, "0000 11 0 0 100 0 0 00 100 000")},
+	} {
+		if m, err := ParseMatchID(tc.id); err == nil {
+			t.Errorf("%s: ParseMatchID(%q) = %+v, want an error", tc.name, tc.id, *m)
+		}
+	}
+}
+
+func TestXGIDMatchID(t *testing.T) {
+	for _, tc := range []struct {
+		xgid string
+		want MatchID
+	}{
+		// XG's player 1 is GnuBG's player 0.
+		{"XGID=-b----E-C---eE---c-e----B-:0:0:1:52:0:0:3:0:10", MatchID{
+			CubeOwner: GNUBG_CUBE_CENTERED, DiceOwner: 0, GameState: GNUBG_GAME_PLAYING, Turn: 0,
+			Dice: [2]int{5, 2},
+		}},
+		{"XGID=-b----E-C---eE---c-e----B-:2:1:-1:31:4:1:0:7:10", MatchID{
+			CubeValue: 2, CubeOwner: 0, DiceOwner: 1, GameState: GNUBG_GAME_PLAYING, Turn: 1,
+			Dice: [2]int{3, 1}, MatchLength: 7, Score: [2]int{4, 1},
+		}},
+		{"XGID=-b----E-C---eE---c-e----B-:0:0:-1:00:4:6:1:7:10", MatchID{
+			CubeOwner: GNUBG_CUBE_CENTERED, DiceOwner: 1, Crawford: true, GameState: GNUBG_GAME_PLAYING,
+			Turn: 1, MatchLength: 7, Score: [2]int{4, 6},
+		}},
+		// On a double the player on roll keeps the dice and the opponent
+		// is on turn to answer.
+		{"XGID=-b----E-C---eE---c-e----B-:0:0:1:D:0:0:0:5:10", MatchID{
+			CubeOwner: GNUBG_CUBE_CENTERED, DiceOwner: 0, GameState: GNUBG_GAME_PLAYING, Turn: 1,
+			Doubled: true, MatchLength: 5,
+		}},
+		{"XGID=-b----E-C---eE---c-e----B-:1:-1:-1:D:0:0:0:5:10", MatchID{
+			CubeValue: 1, CubeOwner: 1, DiceOwner: 1, GameState: GNUBG_GAME_PLAYING, Turn: 0,
+			Doubled: true, MatchLength: 5,
+		}},
+	} {
+		x, err := ParseXGID(tc.xgid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := x.MatchID(); *got != tc.want {
+			t.Errorf("%s: MatchID = %+v, want %+v", tc.xgid, *got, tc.want)
+		}
+	}
+
+	x, err := ParseXGID("XGID=aa-B--C-dE---eC--b-b--B---:0:0:-1:63:4:3:1:5:8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := x.PositionID(), PositionID(x.Position.Flip()); got != want {
+		t.Errorf("PositionID of player 2 on roll = %q, want %q", got, want)
+	}
+	if got, want := x.PositionID(), x.MoveEntry().PositionID(); got != want {
+		t.Errorf("XGID and move entry Position IDs differ: %q, %q", got, want)
+	}
+}
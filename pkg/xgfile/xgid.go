@@ -0,0 +1,290 @@
+package xgfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// XGID is a position with its match state in the form XG uses to share
+// positions as text, such as
+//
+//	XGID=-b----E-C---eE---c-e----B-:0:0:1:52:0:0:3:0:10
+//
+// Player 1 is the bottom player of the XGID: Position is seen from player
+// 1, and CubeOwner and Turn are 1 for player 1, -1 for player 2 and, for
+// the cube, 0 when centered.
+type XGID struct {
+	Position Position
+	// CubeValue is the value of the cube, 1 when it has not been turned.
+	CubeValue int32
+	CubeOwner int32
+	Turn      int32
+	// Dice are the dice rolled by the player on turn, zero when the
+	// player has not rolled. CubeAction is then 'D', 'B' or 'R' when a
+	// double, beaver or raccoon waits for an answer, and 0 otherwise.
+	Dice       [2]int32
+	CubeAction byte
+	Score      [2]int32
+	// CrawfordJacoby is 1 in the Crawford game of a match. In money play
+	// it is 1 with the Jacoby rule, plus 2 when beavers are allowed.
+	CrawfordJacoby int32
+	// MatchLength is 0 for money play.
+	MatchLength int32
+	// MaxCube is the log2 of the highest allowed cube value.
+	MaxCube int32
+}
+
+// XG_MONEY_MATCHLENGTH is the match length XG records for money sessions.
+const XG_MONEY_MATCHLENGTH = 99999
+
+// XGID_DEFAULT_MAXCUBE is the cube limit used when the match header sets
+// none.
+const XGID_DEFAULT_MAXCUBE = 10
+
+// newXGID fills in the match state of an XGID from the match and game
+// headers.
+func newXGID(hme *HeaderMatchEntry, hge *HeaderGameEntry) *XGID {
+	x := &XGID{
+		Score:       [2]int32{hge.Score1, hge.Score2},
+		MatchLength: hme.MatchLength,
+		MaxCube:     hme.CubeLimit,
+	}
+	if x.MatchLength == XG_MONEY_MATCHLENGTH {
+		x.MatchLength = 0
+		x.Score = [2]int32{}
+		if hme.Jacoby {
+			x.CrawfordJacoby |= 1
+		}
+		if hme.Beaver {
+			x.CrawfordJacoby |= 2
+		}
+	} else if hge.CrawfordApply {
+		x.CrawfordJacoby = 1
+	}
+	if x.MaxCube <= 0 {
+		x.MaxCube = XGID_DEFAULT_MAXCUBE
+	}
+	return x
+}
+
+// setCube sets the cube from its XG encoding: the log2 of its value, the
+// sign giving the owner.
+func (x *XGID) setCube(cube int32) {
+	x.CubeValue = 1 << abs32(cube)
+	switch {
+	case cube > 0:
+		x.CubeOwner = 1
+	case cube < 0:
+		x.CubeOwner = -1
+	}
+}
+
+// cubeLog2 returns the log2 of the cube value.
+func (x *XGID) cubeLog2() int32 {
+	var log2 int32
+	for v := x.CubeValue; v > 1; v >>= 1 {
+		log2++
+	}
+	return log2
+}
+
+// cube returns the cube in its XG encoding.
+func (x *XGID) cube() int32 {
+	return x.CubeOwner * x.cubeLog2()
+}
+
+// NewXGIDFromMove returns the XGID of the position before a checker play.
+func NewXGIDFromMove(me *MoveEntry, hme *HeaderMatchEntry, hge *HeaderGameEntry) *XGID {
+	x := newXGID(hme, hge)
+	x.Position = me.StartPosition().Perspective(me.ActiveP, 1)
+	x.Turn = me.ActiveP
+	x.Dice = me.Dice
+	x.setCube(me.CubeA)
+	return x
+}
+
+// NewXGIDFromCube returns the XGID of a cube decision, before the dice
+// are rolled. CubeAction records a double, beaver or raccoon waiting for
+// an answer.
+func NewXGIDFromCube(ce *CubeEntry, hme *HeaderMatchEntry, hge *HeaderGameEntry) *XGID {
+	x := newXGID(hme, hge)
+	x.Position = ce.CubePosition().Perspective(ce.ActiveP, 1)
+	x.Turn = ce.ActiveP
+	x.setCube(ce.CubeB)
+	switch {
+	case ce.RaccoonR != 0:
+		x.CubeAction = 'R'
+	case ce.BeaverR != 0:
+		x.CubeAction = 'B'
+	case ce.Double != 0:
+		x.CubeAction = 'D'
+	}
+	return x
+}
+
+// MatchContext returns the match and game headers holding the match state
+// of the XGID.
+func (x *XGID) MatchContext() (*HeaderMatchEntry, *HeaderGameEntry) {
+	hme := &HeaderMatchEntry{
+		Name:        "MatchInfo",
+		EntryType:   ENTRYTYPE_HEADERMATCH,
+		MatchLength: x.MatchLength,
+		CubeLimit:   x.MaxCube,
+	}
+	hge := &HeaderGameEntry{
+		Name:      "GameHeader",
+		EntryType: ENTRYTYPE_HEADERGAME,
+		Score1:    x.Score[0],
+		Score2:    x.Score[1],
+		PosInit:   StartingPosition,
+	}
+	if x.MatchLength == 0 {
+		hme.MatchLength = XG_MONEY_MATCHLENGTH
+		hme.Jacoby = x.CrawfordJacoby&1 != 0
+		hme.Beaver = x.CrawfordJacoby&2 != 0
+	} else {
+		hme.Crawford = true
+		hge.CrawfordApply = x.CrawfordJacoby&1 != 0
+	}
+	return hme, hge
+}
+
+// MoveEntry returns a checker play record for the position and dice of
+// the XGID. The move itself is left empty.
+func (x *XGID) MoveEntry() *MoveEntry {
+	me := &MoveEntry{
+		Name:      "Move",
+		EntryType: ENTRYTYPE_MOVE,
+		PositionI: x.Position.Perspective(1, x.Turn),
+		ActiveP:   x.Turn,
+		Dice:      x.Dice,
+		CubeA:     x.cube(),
+	}
+	for i := range me.Moves {
+		me.Moves[i] = -1
+	}
+	return me
+}
+
+// CubeEntry returns a cube decision record for the position of the XGID,
+// doubled, beavered or raccooned as CubeAction says.
+func (x *XGID) CubeEntry() *CubeEntry {
+	ce := &CubeEntry{
+		Name:      "Cube",
+		EntryType: ENTRYTYPE_CUBE,
+		ActiveP:   x.Turn,
+		CubeB:     x.cube(),
+		Position:  x.Position.Perspective(1, x.Turn),
+		Take:      -1,
+	}
+	switch x.CubeAction {
+	case 'R':
+		ce.RaccoonR = 1
+		fallthrough
+	case 'B':
+		ce.BeaverR = 1
+		fallthrough
+	case 'D':
+		ce.Double = 1
+	}
+	return ce
+}
+
+// String formats the XGID, with the "XGID=" prefix.
+func (x *XGID) String() string {
+	var b strings.Builder
+	b.WriteString("XGID=")
+	for _, n := range x.Position {
+		switch {
+		case n > 0:
+			b.WriteByte('A' + byte(n) - 1)
+		case n < 0:
+			b.WriteByte('a' + byte(-n) - 1)
+		default:
+			b.WriteByte('-')
+		}
+	}
+
+	dice := fmt.Sprintf("%d%d", x.Dice[0], x.Dice[1])
+	if x.CubeAction != 0 {
+		dice = string(x.CubeAction)
+	}
+	fmt.Fprintf(&b, ":%d:%d:%d:%s:%d:%d:%d:%d:%d",
+		x.cubeLog2(), x.CubeOwner, x.Turn, dice,
+		x.Score[0], x.Score[1], x.CrawfordJacoby, x.MatchLength, x.MaxCube)
+	return b.String()
+}
+
+// ParseXGID parses an XGID, with or without the "XGID=" prefix.
+func ParseXGID(s string) (*XGID, error) {
+	fields := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "XGID="), ":")
+	if len(fields) != 10 {
+		return nil, fmt.Errorf("XGID has %d fields, want 10", len(fields))
+	}
+	if len(fields[0]) != len(Position{}) {
+		return nil, fmt.Errorf("XGID position has %d points, want %d", len(fields[0]), len(Position{}))
+	}
+
+	x := &XGID{}
+	for i, c := range []byte(fields[0]) {
+		switch {
+		case c == '-':
+		case c >= 'A' && c <= 'P':
+			x.Position[i] = int8(c-'A') + 1
+		case c >= 'a' && c <= 'p':
+			x.Position[i] = -int8(c-'a') - 1
+		default:
+			return nil, fmt.Errorf("XGID position: invalid character %q", c)
+		}
+	}
+	if err := x.Position.Validate(); err != nil {
+		return nil, fmt.Errorf("XGID position: %v", err)
+	}
+
+	var values [9]int64
+	for i, field := range fields[1:] {
+		if i == 3 {
+			continue
+		}
+		v, err := strconv.ParseInt(field, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("XGID field %d: %v", i+2, err)
+		}
+		values[i] = v
+	}
+	cube, owner, turn := int32(values[0]), int32(values[1]), int32(values[2])
+	if cube < 0 || cube > 30 {
+		return nil, fmt.Errorf("XGID cube %d out of range", cube)
+	}
+	if owner < -1 || owner > 1 || (cube == 0 && owner != 0) {
+		return nil, fmt.Errorf("XGID cube owner %d out of range", owner)
+	}
+	if turn != 1 && turn != -1 {
+		return nil, fmt.Errorf("XGID turn %d out of range", turn)
+	}
+	x.CubeValue = 1 << cube
+	x.CubeOwner = owner
+	x.Turn = turn
+
+	switch dice := fields[4]; {
+	case dice == "D" || dice == "B" || dice == "R":
+		x.CubeAction = dice[0]
+	case len(dice) == 2 && dice[0] >= '0' && dice[0] <= '6' && dice[1] >= '0' && dice[1] <= '6' &&
+		(dice[0] == '0') == (dice[1] == '0'):
+		x.Dice = [2]int32{int32(dice[0] - '0'), int32(dice[1] - '0')}
+	default:
+		return nil, fmt.Errorf("XGID dice %q invalid", dice)
+	}
+
+	for _, v := range values[4:] {
+		if v < 0 {
+			return nil, fmt.Errorf("XGID score or match setting %d is negative", v)
+		}
+	}
+	x.Score = [2]int32{int32(values[4]), int32(values[5])}
+	x.CrawfordJacoby = int32(values[6])
+	x.MatchLength = int32(values[7])
+	x.MaxCube = int32(values[8])
+	return x, nil
+}
@@ -0,0 +1,144 @@
+package xgfile
+
+import "testing"
+
+func TestParseXGID(t *testing.T) {
+	for _, tc := range []struct {
+		xgid string
+		want XGID
+	}{
+		// The starting position of a money session with the Jacoby rule
+		// and beavers, player 1 having rolled 52.
+		{"XGID=-b----E-C---eE---c-e----B-:0:0:1:52:0:0:3:0:10", XGID{
+			Position: StartingPosition, CubeValue: 1, Turn: 1, Dice: [2]int32{5, 2},
+			CrawfordJacoby: 3, MaxCube: 10,
+		}},
+		// Player 2 to roll after the Crawford game, 4-3 in a 5 point
+		// match, with a 2 cube owned by player 1 and a checker of player 2
+		// on the bar.
+		{"XGID=aa-B--C-dE---eC--b-b--B---:1:1:-1:00:4:3:0:5:8", XGID{
+			Position: Position{
+				1: -1, 3: 2, 6: 3, 8: -4, 9: 5, 13: -5, 14: 3, 17: -2, 19: -2, 22: 2,
+				POS_OPP_BAR: -1,
+			},
+			CubeValue: 2, CubeOwner: 1, Turn: -1, Score: [2]int32{4, 3},
+			MatchLength: 5, MaxCube: 8,
+		}},
+		// Player 1, owning a 2 cube, redoubles.
+		{"XGID=-b----E-C---eE---c-e----B-:1:1:1:D:0:0:0:7:10", XGID{
+			Position: StartingPosition, CubeValue: 2, CubeOwner: 1, Turn: 1, CubeAction: 'D',
+			MatchLength: 7, MaxCube: 10,
+		}},
+	} {
+		x, err := ParseXGID(tc.xgid)
+		if err != nil {
+			t.Errorf("ParseXGID(%q): %v", tc.xgid, err)
+			continue
+		}
+		if *x != tc.want {
+			t.Errorf("ParseXGID(%q) = %+v, want %+v", tc.xgid, *x, tc.want)
+		}
+		if got := x.String(); got != tc.xgid {
+			t.Errorf("ParseXGID(%q).String() = %q", tc.xgid, got)
+		}
+		// The prefix and surrounding space are optional.
+		if x, err := ParseXGID(" " + tc.xgid[len("XGID="):] + "\n"); err != nil || *x != tc.want {
+			t.Errorf("ParseXGID without prefix = %+v, %v", x, err)
+		}
+	}
+}
+
+func TestParseXGIDErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"XGID=-b----E-C---eE---c-e----B-:0:0:1:52:0:0:3:0",
+		"XGID=-b----E-C---eE---c-e----B-:0:0:1:52:0:0:3:0:10:0",
+		"XGID=-b----E-C---eE---c-e---B-:0:0:1:52:0:0:3:0:10",
+		"XGID=-b----E-C---eE---c-e----B--:0:0:1:52:0:0:3:0:10",
+		"XGID=-b----E-C---eE---c-e----Bx:0:0:1:52:0:0:3:0:10",
+		"XGID=-b----E-C---eE---c-e----P-:0:0:1:52:0:0:3:0:10",
+		"XGID=B-----E-C---eE---c-e----B-:0:0:1:52:0:0:3:0:10",
+		"XGID=-b----E-C---eE---c-e----B-:0:0:1:72:0:0:3:0:10",
+		"XGID=-b----E-C---eE---c-e----B-:0:0:1:50:0:0:3:0:10",
+		"XGID=-b----E-C---eE---c-e----B-:0:0:1:5:0:0:3:0:10",
+		"XGID=-b----E-C---eE---c-e----B-:0:0:1:X:0:0:3:0:10",
+		"XGID=-b----E-C---eE---c-e----B-:-1:0:1:52:0:0:3:0:10",
+		"XGID=-b----E-C---eE---c-e----B-:0:1:1:52:0:0:3:0:10",
+		"XGID=-b----E-C---eE---c-e----B-:1:2:1:52:0:0:3:0:10",
+		"XGID=-b----E-C---eE---c-e----B-:0:0:0:52:0:0:3:0:10",
+		"XGID=-b----E-C---eE---c-e----B-:0:0:1:52:-1:0:0:5:10",
+		"XGID=-b----E-C---eE---c-e----B-:0:0:1:52:x:0:0:5:10",
+	} {
+		if x, err := ParseXGID(s); err == nil {
+			t.Errorf("ParseXGID(%q) = %+v, want an error", s, x)
+		}
+	}
+}
+
+// A position read from XG goes through a move or cube record and its
+// match headers back to the same XGID.
+func TestXGIDRecords(t *testing.T) {
+	for _, s := range []string{
+		"XGID=-b----E-C---eE---c-e----B-:0:0:1:52:0:0:3:0:10",
+		"XGID=aa-B--C-dE---eC--b-b--B---:0:0:-1:63:4:3:1:5:8",
+		"XGID=aa-B--C-dE---eC--b-b--B---:1:-1:1:11:2:0:0:7:10",
+	} {
+		x, err := ParseXGID(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hme, hge := x.MatchContext()
+		me := x.MoveEntry()
+		if me.ActiveP != x.Turn || me.Dice != x.Dice || me.Moves[0] != MOVE_END {
+			t.Errorf("%s: move entry %+v", s, me)
+		}
+		if got := NewXGIDFromMove(me, hme, hge).String(); got != s {
+			t.Errorf("%s: move entry gave %s", s, got)
+		}
+		if got := me.StartPosition(); got != x.Position.Perspective(1, x.Turn) {
+			t.Errorf("%s: move entry position %v is not seen from the player on roll", s, got)
+		}
+	}
+}
+
+func TestXGIDMatchContext(t *testing.T) {
+	for _, tc := range []struct {
+		xgid                            string
+		matchLength                     int32
+		crawford, crawfordApply, jacoby bool
+		beaver                          bool
+	}{
+		{"XGID=-b----E-C---eE---c-e----B-:0:0:1:52:0:0:3:0:10", XG_MONEY_MATCHLENGTH, false, false, true, true},
+		{"XGID=-b----E-C---eE---c-e----B-:0:0:1:52:0:0:2:0:10", XG_MONEY_MATCHLENGTH, false, false, false, true},
+		{"XGID=-b----E-C---eE---c-e----B-:0:0:1:52:4:2:1:5:10", 5, true, true, false, false},
+		{"XGID=-b----E-C---eE---c-e----B-:0:0:1:52:4:3:0:5:10", 5, true, false, false, false},
+	} {
+		x, err := ParseXGID(tc.xgid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hme, hge := x.MatchContext()
+		if hme.MatchLength != tc.matchLength || hme.Crawford != tc.crawford || hge.CrawfordApply != tc.crawfordApply ||
+			hme.Jacoby != tc.jacoby || hme.Beaver != tc.beaver || hme.CubeLimit != 10 {
+			t.Errorf("%s: match header %+v, game header %+v", tc.xgid, hme, hge)
+		}
+		if hge.Score1 != x.Score[0] || hge.Score2 != x.Score[1] {
+			t.Errorf("%s: game score %d-%d", tc.xgid, hge.Score1, hge.Score2)
+		}
+	}
+}
+
+func TestXGIDCubeAction(t *testing.T) {
+	for _, action := range []string{"00", "D", "B", "R"} {
+		s := "XGID=-b----E-C---eE---c-e----B-:1:1:-1:" + action + ":0:0:3:0:10"
+		x, err := ParseXGID(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ce := x.CubeEntry()
+		hme, hge := x.MatchContext()
+		if got := NewXGIDFromCube(ce, hme, hge).String(); got != s {
+			t.Errorf("cube entry of %s gave %s", s, got)
+		}
+	}
+}
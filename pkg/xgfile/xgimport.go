@@ -1,19 +1,52 @@
 package xgfile
 
 import (
+	"bytes"
 	"errors"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 )
 
 type Import struct {
 	Filename string
+	// InMemory keeps every segment in memory instead of extracting it to a
+	// temporary file. Segment.File is nil and Segment.Reader reads the
+	// decompressed data.
+	InMemory bool
+	// GameHdr is the decoded temp.xgi segment, set by GetFileSegment. It
+	// is nil when the segment is missing or cannot be decoded, the error
+	// then being appended to Diagnostics.
+	GameHdr *GameHdrRecord
+	// Recover makes GetFileSegment salvage what it can from a damaged
+	// file instead of failing on the first error: damaged entries are
+	// inflated as far as possible and a damaged registry is replaced by a
+	// scan for zlib streams. Each problem is appended to Diagnostics.
+	Recover bool
+	// Diagnostics also lists the problems that never stop an import, such
+	// as checksum mismatches or a damaged temp.xgi.
+	Diagnostics []error
+
+	source     io.ReaderAt
+	sourceSize int64
+}
+
+// SegmentReader gives random and sequential access to the contents of a
+// segment. It is implemented by *os.File, *bytes.Reader and
+// *io.SectionReader.
+type SegmentReader interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
 }
 
+// Segment is one part of an XG file. Filename and File are only set for
+// segments extracted to a temporary file; Reader is always set.
 type Segment struct {
 	Filename   string
 	File       *os.File
+	Reader     SegmentReader
 	Type       int
 	AutoDelete bool
 }
@@ -39,6 +72,7 @@ func NewSegment(segmentType int, autoDelete bool) (*Segment, error) {
 	return &Segment{
 		Filename:   tmpFile.Name(),
 		File:       tmpFile,
+		Reader:     tmpFile,
 		Type:       segmentType,
 		AutoDelete: autoDelete,
 	}, nil
@@ -59,19 +93,13 @@ func (s *Segment) Close() error {
 }
 
 func (s *Segment) CopyTo(dest string) error {
-	srcFile, err := os.Open(s.Filename)
-	if err != nil {
-		return err
-	}
-	defer srcFile.Close()
-
 	destFile, err := os.Create(dest)
 	if err != nil {
 		return err
 	}
 	defer destFile.Close()
 
-	_, err = io.Copy(destFile, srcFile)
+	_, err = io.Copy(destFile, io.NewSectionReader(s.Reader, 0, math.MaxInt64))
 	return err
 }
 
@@ -79,95 +107,206 @@ func NewImport(filename string) *Import {
 	return &Import{Filename: filename}
 }
 
-func (imp *Import) GetFileSegment() ([]*Segment, error) {
-	var segments []*Segment
+// NewImportReaderAt returns an Import that reads the XG data from r, which
+// holds size bytes, instead of opening a file. With InMemory set the
+// segments read from r directly, so r must stay valid while they are used.
+func NewImportReaderAt(r io.ReaderAt, size int64) *Import {
+	return &Import{source: r, sourceSize: size}
+}
 
-	xginfile, err := os.Open(imp.Filename)
-	if err != nil {
-		return nil, err
-	}
-	defer xginfile.Close()
+func (imp *Import) GetFileSegment() (_ []*Segment, err error) {
+	// Segments are closed on error, which removes their temporary files.
+	// They are tracked here as the error returns do not return them.
+	var segments []*Segment
+	defer func() {
+		if err != nil {
+			for _, segment := range segments {
+				segment.Close()
+			}
+		}
+	}()
 
-	// Use the GameDataFormatHdrRecord from xgstruct
-	gdfheader := &xgstruct.GameDataFormatHdrRecord{}
-	if err := gdfheader.FromStream(xginfile); err != nil {
-		return nil, errors.New("not a game data format file")
+	var xginfile SegmentReader
+	if imp.source != nil {
+		xginfile = io.NewSectionReader(imp.source, 0, imp.sourceSize)
+	} else if imp.InMemory {
+		data, err := os.ReadFile(imp.Filename)
+		if err != nil {
+			return nil, err
+		}
+		xginfile = bytes.NewReader(data)
+	} else {
+		file, err := os.Open(imp.Filename)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		xginfile = file
 	}
 
-	segment, err := NewSegment(GDF_HDR, true)
+	size, err := xginfile.Seek(0, io.SeekEnd)
 	if err != nil {
 		return nil, err
 	}
-	defer segment.Close()
-
-	xginfile.Seek(0, io.SeekStart)
-	block := make([]byte, gdfheader.HeaderSize)
-	if _, err := xginfile.Read(block); err != nil {
+	if _, err := xginfile.Seek(0, io.SeekStart); err != nil {
 		return nil, err
 	}
-	if _, err := segment.File.Write(block); err != nil {
-		return nil, err
+
+	// In recovery mode damage is recorded in imp.Diagnostics and the
+	// import carries on with whatever can still be read.
+	imp.Diagnostics = nil
+	imp.GameHdr = nil
+	salvage := func(err error) error {
+		if !imp.Recover {
+			return err
+		}
+		imp.Diagnostics = append(imp.Diagnostics, err)
+		return nil
 	}
-	segment.File.Sync()
-	segments = append(segments, segment)
 
-	if gdfheader.ThumbnailSize > 0 {
-		segment, err := NewSegment(GDF_IMAGE, true)
-		if err != nil {
+	var archiveStart int64
+	gdfheader := &GameDataFormatHdrRecord{}
+	if err := gdfheader.FromStream(xginfile); err != nil {
+		if err := salvage(withContext(err, "GDF header", 0, -1)); err != nil {
+			return nil, err
+		}
+		gdfheader = nil
+	} else if int64(gdfheader.HeaderSize) > size ||
+		gdfheader.ThumbnailOffset > size-int64(gdfheader.ThumbnailSize) {
+		// Check the sizes read from the file before allocating them.
+		cause := errors.New("header or thumbnail extends past the end of the file")
+		if err := salvage(withContext(newError(ErrCorrupt, 8, cause), "GDF header", 0, -1)); err != nil {
 			return nil, err
 		}
-		defer segment.Close()
+		gdfheader = nil
+	}
 
-		xginfile.Seek(gdfheader.ThumbnailOffset, io.SeekStart)
-		imgbuf := make([]byte, gdfheader.ThumbnailSize)
-		if _, err := xginfile.Read(imgbuf); err != nil {
+	if gdfheader != nil {
+		block := make([]byte, gdfheader.HeaderSize)
+		if _, err := xginfile.ReadAt(block, 0); err != nil {
 			return nil, err
 		}
-		if _, err := segment.File.Write(imgbuf); err != nil {
+		segment, err := imp.newDataSegment(GDF_HDR, block)
+		if err != nil {
 			return nil, err
 		}
-		segment.File.Sync()
 		segments = append(segments, segment)
-	}
+		archiveStart = int64(gdfheader.HeaderSize)
 
-	archiveobj, err := NewZlibArchive(imp.Filename)
-	if err != nil {
-		return nil, err
+		if gdfheader.ThumbnailSize > 0 {
+			imgbuf := make([]byte, gdfheader.ThumbnailSize)
+			if _, err := xginfile.ReadAt(imgbuf, gdfheader.ThumbnailOffset); err != nil {
+				return nil, err
+			}
+			segment, err := imp.newDataSegment(GDF_IMAGE, imgbuf)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment)
+			if end := gdfheader.ThumbnailOffset + int64(gdfheader.ThumbnailSize); end > archiveStart {
+				archiveStart = end
+			}
+		}
 	}
 
-	for _, filerec := range archiveobj.ArcRegistry {
-		segmentFile, segFilename, err := archiveobj.GetArchiveFile(filerec)
+	// Archive entries are added to segments as they are extracted, so an
+	// error on a later entry closes them too.
+	firstEntry := len(segments)
+	archiveobj, err := newZlibArchive(xginfile)
+	if err != nil {
+		if err := salvage(err); err != nil {
+			return nil, err
+		}
+		scanned, err := imp.scanSegments(xginfile, archiveStart, size)
 		if err != nil {
 			return nil, err
 		}
-		defer segmentFile.Close()
-		defer os.Remove(segFilename)
+		segments = append(segments, scanned...)
+	} else {
+		archiveobj.Filename = imp.Filename
+		for _, filerec := range archiveobj.ArcRegistry {
+			xgFileType, ok := XG_FILEMAP[filepath.Base(filerec.Name)]
+			if !ok {
+				xgFileType = XG_UNKNOWN
+			}
 
-		xgFileType := XG_FILEMAP[filepath.Base(filerec.Name)]
-		xgFileSegment := &Segment{
-			Filename:   segFilename,
-			File:       segmentFile,
-			Type:       xgFileType,
-			AutoDelete: false,
+			xgFileSegment := &Segment{Type: xgFileType}
+			if imp.InMemory {
+				reader, err := archiveobj.OpenArchiveFile(filerec)
+				if err != nil {
+					xgFileSegment, err = imp.salvageEntry(archiveobj, filerec, xgFileType, err)
+					if err != nil {
+						return nil, err
+					}
+				} else {
+					xgFileSegment.Reader = reader
+				}
+			} else {
+				segmentFile, err := archiveobj.GetArchiveFile(filerec)
+				if err != nil {
+					xgFileSegment, err = imp.salvageEntry(archiveobj, filerec, xgFileType, err)
+					if err != nil {
+						return nil, err
+					}
+				} else {
+					xgFileSegment.Filename = segmentFile.Name()
+					xgFileSegment.File = segmentFile
+					xgFileSegment.Reader = segmentFile
+					xgFileSegment.AutoDelete = true
+				}
+			}
+			if xgFileSegment != nil {
+				segments = append(segments, xgFileSegment)
+			}
 		}
+		imp.Diagnostics = append(imp.Diagnostics, archiveobj.ChecksumErrors...)
+	}
 
-		if xgFileType == XG_GAMEFILE {
-			segmentFile.Seek(XG_GAMEHDR_LEN, io.SeekStart)
-			magicStr := make([]byte, 4)
-			if _, err := segmentFile.Read(magicStr); err != nil {
-				return nil, err
-			}
-			if string(magicStr) != "DMLI" {
-				return nil, errors.New("not a valid XG gamefile")
+	for _, xgFileSegment := range segments[firstEntry:] {
+		if xgFileSegment.Type == XG_GAMEHDR {
+			// temp.xgi only repeats the match header of the game file, so
+			// a damaged copy is reported rather than failing the import.
+			gameHdr, err := xgFileSegment.GameHdr()
+			if err != nil {
+				imp.Diagnostics = append(imp.Diagnostics, err)
+			} else {
+				imp.GameHdr = gameHdr
 			}
 		}
 
-		segments = append(segments, xgFileSegment)
+		if xgFileSegment.Type == XG_GAMEFILE {
+			if err := checkGameFileMagic(xgFileSegment.Reader); err != nil {
+				if err := salvage(err); err != nil {
+					return nil, err
+				}
+			}
+		}
 	}
 
 	return segments, nil
 }
 
+// newDataSegment returns a segment holding data, backed by memory or by a
+// temporary file depending on the import mode.
+func (imp *Import) newDataSegment(segmentType int, data []byte) (*Segment, error) {
+	if imp.InMemory {
+		return &Segment{Type: segmentType, Reader: bytes.NewReader(data)}, nil
+	}
+	segment, err := NewSegment(segmentType, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := segment.File.Write(data); err != nil {
+		segment.Close()
+		return nil, err
+	}
+	if _, err := segment.File.Seek(0, io.SeekStart); err != nil {
+		segment.Close()
+		return nil, err
+	}
+	return segment, nil
+}
+
 var XG_FILEMAP = map[string]int{
 	"temp.xgi": XG_GAMEHDR,
 	"temp.xgr": XG_ROLLOUTS,
@@ -175,4 +314,7 @@ var XG_FILEMAP = map[string]int{
 	"temp.xg":  XG_GAMEFILE,
 }
 
+// XG_GAMEHDR_LEN is the offset of the "DMLI" magic in temp.xgi and
+// temp.xg. Both start with a HeaderMatchEntry record and the magic is its
+// Magic field, so this is not the length of a prefix before the records.
 const XG_GAMEHDR_LEN = 556
@@ -0,0 +1,269 @@
+package xgfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"testing"
+)
+
+// testMatchRecords returns a short match of the given record version: a
+// game with a double, a take and a move, won by a resignation.
+func testMatchRecords(version int32) []*GameFileRecord {
+	hme := &HeaderMatchEntry{
+		Name: "MatchInfo", SPlayer1: "Alice", SPlayer2: "Bob",
+		Player1: "Alice", Player2: "Bob", MatchLength: 5, Crawford: true,
+		Date: "2024-03-01 12:00:00", CommentHeaderMatch: -1, CommentFooterMatch: -1,
+		SiteId: -1, Version: version,
+	}
+	hge := NewHeaderGameEntry(version)
+	hge.Name = "GameHeader"
+	hge.EntryType = ENTRYTYPE_HEADERGAME
+	hge.GameNumber = 1
+	hge.PosInit = StartingPosition
+	hge.CommentHeaderGame = -1
+	hge.CommentFooterGame = -1
+
+	ce := NewCubeEntry(version)
+	ce.Name = "Cube"
+	ce.EntryType = ENTRYTYPE_CUBE
+	ce.ActiveP = 1
+	ce.Double = 1
+	ce.Take = 1
+	ce.Position = StartingPosition
+	ce.DiceRolled = "31"
+	ce.CommentCube = -1
+
+	me := NewMoveEntry(version)
+	me.Name = "Move"
+	me.EntryType = ENTRYTYPE_MOVE
+	me.ActiveP = 1
+	me.Dice = [2]int32{3, 1}
+	me.Moves = [8]int32{8, 5, 6, 5, -1, -1, -1, -1}
+	me.PositionI = StartingPosition
+	me.CommentMove = -1
+
+	fge := &FooterGameEntry{
+		Name: "GameFooter", EntryType: ENTRYTYPE_FOOTERGAME, Score1g: 4,
+		Winner: 1, PointsWon: 4, Termination: TERMINATION_RESIGN + 2,
+	}
+	fme := &FooterMatchEntry{
+		Name: "MatchFooter", EntryType: ENTRYTYPE_FOOTERMATCH, Score1m: 4,
+		WinnerM: 1, Datem: "2024-03-01 13:00:00",
+	}
+
+	var records []*GameFileRecord
+	for _, rec := range []interface{}{hme, hge, ce, me, fge, fme} {
+		records = append(records, &GameFileRecord{Record: rec, Version: version})
+	}
+	return records
+}
+
+// testExportBytes returns the XG file written by Export for
+// testMatchRecords, using the given compression level.
+func testExportBytes(t testing.TB, version int32, level int) []byte {
+	t.Helper()
+	exp := NewExport()
+	exp.CompressionLevel = level
+	exp.Records = testMatchRecords(version)
+	exp.Comments = []Comment{{RTF: `{\rtf1\ansi Hello}`}}
+	var buf bytes.Buffer
+	if _, err := exp.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testArchiveBytes returns an XG file with a GDF header and an archive
+// holding the given entries, which need not be valid segments.
+func testArchiveBytes(t testing.TB, entries ...[2]string) []byte {
+	t.Helper()
+	hdr := GameDataFormatHdrRecord{HeaderVersion: 1, HeaderSize: GDF_HDR_LEN}
+	packedHdr, err := hdr.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBuffer(packedHdr)
+	zw := NewZlibArchiveWriter(buf)
+	for _, entry := range entries {
+		if err := zw.AddFile(entry[0], []byte(entry[1]), 6); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testSegmentBytes returns the whole content of a segment.
+func testSegmentBytes(t testing.TB, segment *Segment) []byte {
+	t.Helper()
+	data, err := io.ReadAll(io.NewSectionReader(segment.Reader, 0, math.MaxInt64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestImportBadGameHdr(t *testing.T) {
+	data := testExportBytes(t, 28, 6)
+	segments, err := NewImportReaderAt(bytes.NewReader(data), int64(len(data))).GetFileSegment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gameFile []byte
+	for _, segment := range segments {
+		if segment.Type == XG_GAMEFILE {
+			gameFile = testSegmentBytes(t, segment)
+		}
+		segment.Close()
+	}
+
+	data = testArchiveBytes(t, [2]string{"temp.xgi", "not a game header"}, [2]string{"temp.xg", string(gameFile)})
+	for _, inMemory := range []bool{true, false} {
+		imp := NewImportReaderAt(bytes.NewReader(data), int64(len(data)))
+		imp.InMemory = inMemory
+		segments, err := imp.GetFileSegment()
+		if err != nil {
+			t.Fatalf("InMemory %v: %v", inMemory, err)
+		}
+		if imp.GameHdr != nil {
+			t.Errorf("InMemory %v: GameHdr set from a damaged temp.xgi", inMemory)
+		}
+		if len(imp.Diagnostics) != 1 {
+			t.Errorf("InMemory %v: Diagnostics = %v, want the temp.xgi error", inMemory, imp.Diagnostics)
+		}
+		m, err := ReadMatch(segments)
+		if err != nil {
+			t.Errorf("InMemory %v: ReadMatch: %v", inMemory, err)
+		} else if m.Player1 != "Alice" || len(m.Games) != 1 {
+			t.Errorf("InMemory %v: ReadMatch gave %q with %d games", inMemory, m.Player1, len(m.Games))
+		}
+		for _, segment := range segments {
+			segment.Close()
+		}
+	}
+}
+
+func TestGameHdrRecordVersion0(t *testing.T) {
+	ghr := GameHdrRecord{Match: *testMatchRecords(0)[0].Record.(*HeaderMatchEntry)}
+	packedData, err := ghr.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &GameHdrRecord{}
+	if err := got.FromStream(bytes.NewReader(packedData)); err != nil {
+		t.Fatal(err)
+	}
+	hme := &HeaderMatchEntry{}
+	if err := hme.FromStream(bytes.NewReader(packedData)); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != 0 || got.Match != *hme {
+		t.Errorf("GameHdrRecord version 0 = %+v, want match %+v", got, hme)
+	}
+}
+
+// testTempFiles makes the test create its temporary files in a directory
+// of its own and returns a function listing them.
+func testTempFiles(t *testing.T) func() []string {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	return func() []string {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+}
+
+func TestImportErrorRemovesTempFiles(t *testing.T) {
+	tempFiles := testTempFiles(t)
+	data := testExportBytes(t, 28, 6)
+	// Damage the archive record, so the archive fails to open after the
+	// GDF header segment has been extracted.
+	binary.LittleEndian.PutUint32(data[len(data)-ARCHIVEREC_LEN+4:], 0xffffff)
+
+	imp := NewImportReaderAt(bytes.NewReader(data), int64(len(data)))
+	if segments, err := imp.GetFileSegment(); err == nil {
+		t.Fatalf("GetFileSegment returned %d segments from a damaged archive", len(segments))
+	}
+	if names := tempFiles(); len(names) != 0 {
+		t.Errorf("temporary files left behind: %v", names)
+	}
+}
+
+func TestImportEntryErrorRemovesTempFiles(t *testing.T) {
+	tempFiles := testTempFiles(t)
+	data := testExportBytes(t, 28, 6)
+	// Damage the zlib checksum of the comments, the last entry, so it
+	// fails to inflate after the other entries have been extracted.
+	za, err := newZlibArchive(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := za.ArcRegistry[len(za.ArcRegistry)-1]
+	data[za.StartOfArcData+int64(last.Start+last.CSize)-1] ^= 1
+
+	imp := NewImportReaderAt(bytes.NewReader(data), int64(len(data)))
+	if segments, err := imp.GetFileSegment(); err == nil {
+		t.Fatalf("GetFileSegment returned %d segments from a damaged entry", len(segments))
+	}
+	if names := tempFiles(); len(names) != 0 {
+		t.Errorf("temporary files left behind: %v", names)
+	}
+}
+
+// A record of an entry type added by a later XG release is skipped.
+func TestReadMatchUnknownEntryType(t *testing.T) {
+	var gameFile bytes.Buffer
+	for i, gfr := range testMatchRecords(28) {
+		if i == 4 {
+			unknown := make([]byte, XG_GAMEFILEREC_LEN)
+			unknown[8] = ENTRYTYPE_MISSING + 3
+			gameFile.Write(unknown)
+		}
+		packedData, err := gfr.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		gameFile.Write(packedData)
+	}
+	gameHdr := gameFile.Bytes()[:XG_GAMEFILEREC_LEN]
+	data := testArchiveBytes(t, [2]string{"temp.xgi", string(gameHdr)}, [2]string{"temp.xg", gameFile.String()})
+
+	imp := NewImportReaderAt(bytes.NewReader(data), int64(len(data)))
+	imp.InMemory = true
+	segments, err := imp.GetFileSegment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadMatch(segments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Games) != 1 || len(m.Games[0].Actions) != 4 || m.Winner != 1 {
+		t.Errorf("match has %d games, winner %d", len(m.Games), m.Winner)
+	}
+
+	records, err := readGameFileRecords(segments[len(segments)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	gfr := records[4]
+	if _, ok := gfr.Record.(*UnimplementedEntry); !ok || gfr.EntryType != ENTRYTYPE_MISSING+3 {
+		t.Errorf("record 4 is %T of entry type %d, want an UnimplementedEntry", gfr.Record, gfr.EntryType)
+	}
+	if _, err := gfr.MarshalBinary(); !errors.Is(err, ErrUnknownEntryType) {
+		t.Errorf("encoding an unknown entry gave %v, want ErrUnknownEntryType", err)
+	}
+}
@@ -0,0 +1,327 @@
+package xgfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Match is a match or money session decoded from the game file, with the
+// comments and rollouts it refers to resolved.
+type Match struct {
+	Player1     string
+	Player2     string
+	MatchLength int32
+	Crawford    bool
+	Jacoby      bool
+	Beaver      bool
+	AutoDouble  bool
+	CubeLimit   int32
+	Event       string
+	Location    string
+	Round       string
+	Date        time.Time
+	Games       []*Game
+	// Winner is 1 or 2, or 0 when the match is unfinished.
+	Winner        int
+	Comment       *Comment
+	FooterComment *Comment
+
+	// Header and Footer are the records the match was built from. Footer
+	// is nil when the match is unfinished.
+	Header *HeaderMatchEntry
+	Footer *FooterMatchEntry
+}
+
+// Game is one game of a match.
+type Game struct {
+	Number int32
+	// Score is the match score of each player before the game.
+	Score           [2]int32
+	Crawford        bool
+	InitialPosition Position
+	Actions         []*Action
+	// Winner is 1 or 2, or 0 when the game is unfinished.
+	Winner        int
+	PointsWon     int32
+	Termination   int32
+	Comment       *Comment
+	FooterComment *Comment
+
+	Header *HeaderGameEntry
+	Footer *FooterGameEntry
+}
+
+// Action types.
+const (
+	ACTION_MOVE = iota
+	ACTION_DOUBLE
+	ACTION_TAKE
+	ACTION_BEAVER
+	ACTION_DROP
+	ACTION_RESIGN
+	ACTION_RACCOON
+)
+
+var ACTION_NAMES = []string{"move", "double", "take", "beaver", "drop", "resign", "raccoon"}
+
+// Action is one step of a game. Dice and Moves are set for ACTION_MOVE,
+// CubeValue for the cube actions and Points for ACTION_RESIGN.
+type Action struct {
+	Type int
+	// Player is the player taking the action, 1 or 2.
+	Player    int
+	Dice      [2]int32
+	Moves     [8]int32
+	CubeValue int32
+	Points    int32
+	Comment   *Comment
+	// Rollouts of a move are indexed like the candidates of
+	// Move.DataMoves; the rollout of a cube action, if any, is the only
+	// element.
+	Rollouts []*RolloutContextEntry
+
+	// Move or Cube is the record the action was built from. Resignations
+	// come from the game footer and have neither.
+	Move *MoveEntry
+	Cube *CubeEntry
+}
+
+func (a *Action) String() string {
+	switch a.Type {
+	case ACTION_MOVE:
+		var pos Position
+		if a.Move != nil {
+			pos = a.Move.StartPosition()
+		}
+		return fmt.Sprintf("player %d rolls %d%d: %s", a.Player, a.Dice[0], a.Dice[1], FormatMove(pos, a.Moves))
+	case ACTION_DOUBLE:
+		return fmt.Sprintf("player %d doubles to %d", a.Player, a.CubeValue)
+	case ACTION_RESIGN:
+		return fmt.Sprintf("player %d resigns %d points", a.Player, a.Points)
+	}
+	return fmt.Sprintf("player %d %ss", a.Player, ACTION_NAMES[a.Type])
+}
+
+// Terminations of FooterGameEntry.Termination at or above
+// TERMINATION_RESIGN are resignations, the remainder giving the
+// resignation level: 1 single, 2 gammon, 3 backgammon.
+const (
+	TERMINATION_RESIGN = 100
+	TERMINATION_SETTLE = 1000
+)
+
+// playerOf converts XG's player sign, 1 for the first player and -1 for
+// the second, to a player number. Any other value gives 0.
+func playerOf(sign int32) int {
+	switch sign {
+	case 1:
+		return 1
+	case -1:
+		return 2
+	}
+	return 0
+}
+
+// NewMatch builds a match from the records of a game file, as returned by
+// GameFileReader. rollouts and comments may be nil, in which case actions
+// carry no rollouts or comments.
+func NewMatch(records []*GameFileRecord, rollouts *RolloutFile, comments *CommentFile) (*Match, error) {
+	if rollouts == nil {
+		rollouts = &RolloutFile{}
+	}
+	if comments == nil {
+		comments = &CommentFile{}
+	}
+	if len(records) == 0 {
+		return nil, errors.New("no game file records")
+	}
+	hme, ok := records[0].Record.(*HeaderMatchEntry)
+	if !ok {
+		return nil, errors.New("first game file record is not a match header")
+	}
+
+	m := &Match{
+		Player1:       hme.Player1,
+		Player2:       hme.Player2,
+		MatchLength:   hme.MatchLength,
+		Crawford:      hme.Crawford,
+		Jacoby:        hme.Jacoby,
+		Beaver:        hme.Beaver,
+		AutoDouble:    hme.AutoDouble,
+		CubeLimit:     hme.CubeLimit,
+		Event:         hme.Event,
+		Location:      hme.Location,
+		Round:         hme.Round,
+		Comment:       comments.MatchHeaderComment(hme),
+		FooterComment: comments.MatchFooterComment(hme),
+		Header:        hme,
+	}
+	// Unicode names were added in later versions; older files only have
+	// the short strings.
+	if m.Player1 == "" {
+		m.Player1 = hme.SPlayer1
+	}
+	if m.Player2 == "" {
+		m.Player2 = hme.SPlayer2
+	}
+	if m.Event == "" {
+		m.Event = hme.SEvent
+	}
+	if m.Location == "" {
+		m.Location = hme.SLocation
+	}
+	if m.Round == "" {
+		m.Round = hme.SRound
+	}
+	m.Date, _ = time.Parse(delphiDateLayout, hme.Date)
+
+	var game *Game
+	for i, gfr := range records[1:] {
+		switch rec := gfr.Record.(type) {
+		case *HeaderGameEntry:
+			game = &Game{
+				Number:          rec.GameNumber,
+				Score:           [2]int32{rec.Score1, rec.Score2},
+				Crawford:        rec.CrawfordApply,
+				InitialPosition: Position(rec.PosInit),
+				Comment:         comments.GameHeaderComment(rec),
+				FooterComment:   comments.GameFooterComment(rec),
+				Header:          rec,
+			}
+			m.Games = append(m.Games, game)
+		case *MoveEntry:
+			if game == nil {
+				return nil, fmt.Errorf("game file record %d: move outside a game", i+1)
+			}
+			action := &Action{
+				Type:    ACTION_MOVE,
+				Player:  playerOf(rec.ActiveP),
+				Dice:    rec.Dice,
+				Moves:   rec.Moves,
+				Comment: comments.MoveComment(rec),
+				Move:    rec,
+			}
+			for candidate := range rec.RolloutIndexM {
+				action.Rollouts = append(action.Rollouts, rollouts.MoveRollout(rec, candidate))
+			}
+			game.Actions = append(game.Actions, action)
+		case *CubeEntry:
+			if game == nil {
+				return nil, fmt.Errorf("game file record %d: cube action outside a game", i+1)
+			}
+			game.Actions = append(game.Actions, cubeActions(rec, rollouts, comments)...)
+		case *FooterGameEntry:
+			if game == nil {
+				return nil, fmt.Errorf("game file record %d: game footer outside a game", i+1)
+			}
+			game.Winner = playerOf(rec.Winner)
+			game.PointsWon = rec.PointsWon
+			game.Termination = rec.Termination
+			game.Footer = rec
+			if game.Winner != 0 && rec.Termination >= TERMINATION_RESIGN && rec.Termination < TERMINATION_SETTLE {
+				game.Actions = append(game.Actions, &Action{
+					Type:   ACTION_RESIGN,
+					Player: 3 - game.Winner,
+					Points: rec.PointsWon,
+				})
+			}
+			game = nil
+		case *FooterMatchEntry:
+			m.Winner = playerOf(rec.WinnerM)
+			m.Footer = rec
+		}
+	}
+	return m, nil
+}
+
+// cubeActions returns the actions of a cube decision: nothing when the
+// player did not double, otherwise the double and the response if one
+// was recorded. A beaver is followed by the doubler's raccoon when
+// RaccoonR is set.
+func cubeActions(ce *CubeEntry, rollouts *RolloutFile, comments *CommentFile) []*Action {
+	if ce.Double != 1 {
+		return nil
+	}
+	player := playerOf(ce.ActiveP)
+	// CubeB is the log2 of the cube value before the double, its sign
+	// giving the owner.
+	value := int32(1) << (abs32(ce.CubeB) + 1)
+	double := &Action{
+		Type:      ACTION_DOUBLE,
+		Player:    player,
+		CubeValue: value,
+		Comment:   comments.CubeComment(ce),
+		Rollouts:  []*RolloutContextEntry{rollouts.CubeRollout(ce)},
+		Cube:      ce,
+	}
+	response := &Action{Player: 3 - player, CubeValue: value, Cube: ce}
+	switch {
+	case ce.Take == 2 || ce.BeaverR != 0 || ce.RaccoonR != 0:
+		response.Type = ACTION_BEAVER
+		response.CubeValue = 2 * value
+		if ce.RaccoonR != 0 {
+			raccoon := &Action{Type: ACTION_RACCOON, Player: player, CubeValue: 4 * value, Cube: ce}
+			return []*Action{double, response, raccoon}
+		}
+	case ce.Take == 0:
+		response.Type = ACTION_DROP
+	case ce.Take == 1:
+		response.Type = ACTION_TAKE
+	default:
+		return []*Action{double}
+	}
+	return []*Action{double, response}
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// ReadMatch builds the match held in the segments returned by
+// Import.GetFileSegment.
+func ReadMatch(segments []*Segment) (*Match, error) {
+	var records []*GameFileRecord
+	var rollouts *RolloutFile
+	var comments *CommentFile
+	for _, segment := range segments {
+		var err error
+		switch segment.Type {
+		case XG_GAMEFILE:
+			records, err = readGameFileRecords(segment)
+		case XG_ROLLOUTS:
+			rollouts, err = segment.RolloutFile()
+		case XG_COMMENT:
+			comments, err = segment.CommentFile()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if records == nil {
+		return nil, errors.New("no game file segment")
+	}
+	return NewMatch(records, rollouts, comments)
+}
+
+func readGameFileRecords(segment *Segment) ([]*GameFileRecord, error) {
+	gr, err := segment.GameFileReader()
+	if err != nil {
+		return nil, err
+	}
+	var records []*GameFileRecord
+	for {
+		gfr, err := gr.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, gfr)
+	}
+}
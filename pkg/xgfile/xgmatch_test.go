@@ -0,0 +1,98 @@
+package xgfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCubeActions(t *testing.T) {
+	type action struct {
+		Type      int
+		Player    int
+		CubeValue int32
+	}
+	for _, tc := range []struct {
+		name                            string
+		double, take, beaverR, raccoonR int32
+		activeP, cubeB                  int32
+		want                            []action
+	}{
+		{"no double", 0, -1, 0, 0, 1, 0, nil},
+		{"no double with a take", 0, 1, 0, 0, 1, 0, nil},
+		{"double", 1, -1, 0, 0, 1, 0, []action{{ACTION_DOUBLE, 1, 2}}},
+		{"take", 1, 1, 0, 0, 1, 0, []action{{ACTION_DOUBLE, 1, 2}, {ACTION_TAKE, 2, 2}}},
+		{"pass", 1, 0, 0, 0, -1, 1, []action{{ACTION_DOUBLE, 2, 4}, {ACTION_DROP, 1, 4}}},
+		{"redouble", 1, 1, 0, 0, -1, -2, []action{{ACTION_DOUBLE, 2, 8}, {ACTION_TAKE, 1, 8}}},
+		{"beaver", 1, 2, 0, 0, 1, 0, []action{{ACTION_DOUBLE, 1, 2}, {ACTION_BEAVER, 2, 4}}},
+		{"beaver flag", 1, -1, 1, 0, 1, 0, []action{{ACTION_DOUBLE, 1, 2}, {ACTION_BEAVER, 2, 4}}},
+		{"raccoon", 1, 2, 1, 1, 1, 0, []action{{ACTION_DOUBLE, 1, 2}, {ACTION_BEAVER, 2, 4}, {ACTION_RACCOON, 1, 8}}},
+		{"raccoon by player 2", 1, 2, 1, 1, -1, 0, []action{{ACTION_DOUBLE, 2, 2}, {ACTION_BEAVER, 1, 4}, {ACTION_RACCOON, 2, 8}}},
+		{"raccoon flag only", 1, -1, 0, 1, 1, 0, []action{{ACTION_DOUBLE, 1, 2}, {ACTION_BEAVER, 2, 4}, {ACTION_RACCOON, 1, 8}}},
+	} {
+		ce := &CubeEntry{
+			Double: tc.double, Take: tc.take, BeaverR: tc.beaverR, RaccoonR: tc.raccoonR,
+			ActiveP: tc.activeP, CubeB: tc.cubeB, CommentCube: -1,
+		}
+		var got []action
+		for _, a := range cubeActions(ce, &RolloutFile{}, &CommentFile{}) {
+			got = append(got, action{a.Type, a.Player, a.CubeValue})
+			if a.Cube != ce {
+				t.Errorf("%s: %v does not point to its cube entry", tc.name, a)
+			}
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: actions %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNewMatch(t *testing.T) {
+	records := testMatchRecords(28)
+	ce := records[2].Record.(*CubeEntry)
+	ce.Take = 2
+	ce.BeaverR = 1
+	ce.RaccoonR = 1
+
+	m, err := NewMatch(records, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Player1 != "Alice" || m.Player2 != "Bob" || m.MatchLength != 5 || m.Winner != 1 || len(m.Games) != 1 {
+		t.Fatalf("match %+v", m)
+	}
+	var got []string
+	for _, a := range m.Games[0].Actions {
+		got = append(got, a.String())
+	}
+	want := []string{
+		"player 1 doubles to 2",
+		"player 2 beavers",
+		"player 1 raccoons",
+		"player 1 rolls 31: 8/5 6/5",
+		"player 2 resigns 4 points",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("actions %q, want %q", got, want)
+	}
+	if g := m.Games[0]; g.Winner != 1 || g.PointsWon != 4 || g.Number != 1 {
+		t.Errorf("game %+v", g)
+	}
+}
+
+func TestNewMatchErrors(t *testing.T) {
+	records := testMatchRecords(28)
+	for _, tc := range []struct {
+		name    string
+		records []*GameFileRecord
+	}{
+		{"no records", nil},
+		{"no match header", records[1:]},
+		{"move outside a game", []*GameFileRecord{records[0], records[3]}},
+		{"cube outside a game", []*GameFileRecord{records[0], records[2]}},
+		{"footer outside a game", []*GameFileRecord{records[0], records[4]}},
+	} {
+		if _, err := NewMatch(tc.records, nil, nil); err == nil {
+			t.Errorf("%s: no error", tc.name)
+		}
+	}
+}
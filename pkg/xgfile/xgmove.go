@@ -0,0 +1,294 @@
+package xgfile
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A checker play is a list of from and to points, one pair per die used,
+// numbered from the player's side as in Position. The list ends with
+// MOVE_END; a checker entering from the bar moves from MOVE_BAR and one
+// borne off moves to MOVE_OFF.
+const (
+	MOVE_END = -1
+	MOVE_OFF = -2
+	MOVE_BAR = POS_BAR
+)
+
+// hop is one step of a checker, hit is set when it lands on a blot.
+type hop struct {
+	from, to int
+	hit      bool
+}
+
+// FormatMove formats a checker play in the usual notation, such as
+// "24/18 13/11", "8/5(2)", "bar/22*" or "6/off". pos is the position
+// before the play, seen from the player moving, and is used to mark hits.
+// Points are numbered from the player moving. An empty play formats as
+// "".
+func FormatMove(pos Position, moves [8]int32) string {
+	return formatMove(pos, moves, false)
+}
+
+// formatMove is FormatMove numbering the points from the opponent when
+// mirror is set.
+func formatMove(pos Position, moves [8]int32, mirror bool) string {
+	board := pos
+	var hops []hop
+	for i := 0; i+1 < len(moves); i += 2 {
+		from, to := int(moves[i]), int(moves[i+1])
+		if from == MOVE_END || to == MOVE_END {
+			break
+		}
+		if to <= 0 {
+			// XG also writes 0 for a checker borne off.
+			to = MOVE_OFF
+		}
+		h := hop{from: from, to: to}
+		if from >= 0 && from < len(board) && board[from] > 0 {
+			board[from]--
+		}
+		if to > 0 && to < POS_BAR {
+			if board[to] == -1 {
+				h.hit = true
+				board[to] = 0
+				board[POS_OPP_BAR]--
+			}
+			board[to]++
+		}
+		hops = append(hops, h)
+	}
+
+	// Chain the hops of each checker, so 24/21 21/18 becomes 24/18.
+	var chains [][]hop
+	for _, h := range hops {
+		joined := false
+		for i := range chains {
+			last := chains[i][len(chains[i])-1]
+			if last.to == h.from && last.to != MOVE_OFF {
+				chains[i] = append(chains[i], h)
+				joined = true
+				break
+			}
+		}
+		if !joined {
+			chains = append(chains, []hop{h})
+		}
+	}
+
+	pointName := func(point int) string {
+		switch {
+		case point == MOVE_BAR:
+			return "bar"
+		case point == MOVE_OFF:
+			return "off"
+		case mirror:
+			return strconv.Itoa(25 - point)
+		}
+		return strconv.Itoa(point)
+	}
+
+	type part struct {
+		from, to int
+		text     string
+		count    int
+	}
+	var parts []*part
+	for _, chain := range chains {
+		var b strings.Builder
+		b.WriteString(pointName(chain[0].from))
+		for i, h := range chain {
+			// Intermediate points are only shown when a blot is hit there.
+			if i < len(chain)-1 && !h.hit {
+				continue
+			}
+			b.WriteString("/")
+			b.WriteString(pointName(h.to))
+			if h.hit {
+				b.WriteString("*")
+			}
+		}
+		text := b.String()
+		found := false
+		for _, p := range parts {
+			if p.text == text {
+				p.count++
+				found = true
+				break
+			}
+		}
+		if !found {
+			parts = append(parts, &part{from: chain[0].from, to: chain[len(chain)-1].to, text: text, count: 1})
+		}
+	}
+
+	// Highest starting point first, from the player moving.
+	sort.SliceStable(parts, func(i, j int) bool {
+		if parts[i].from != parts[j].from {
+			return parts[i].from > parts[j].from
+		}
+		return parts[i].to > parts[j].to
+	})
+	texts := make([]string, len(parts))
+	for i, p := range parts {
+		texts[i] = p.text
+		if p.count > 1 {
+			texts[i] += fmt.Sprintf("(%d)", p.count)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// Notation formats the move played.
+func (me *MoveEntry) Notation() string {
+	return FormatMove(me.StartPosition(), me.Moves)
+}
+
+// NotationFor formats the move played with the points numbered from
+// player, 1 or -1 as in ActiveP.
+func (me *MoveEntry) NotationFor(player int32) string {
+	return formatMove(me.StartPosition(), me.Moves, player != me.ActiveP)
+}
+
+// CandidateNotation formats the candidate play at the given index of the
+// analysis, or returns "" when there is none.
+func (esbmr *EngineStructBestMoveRecord) CandidateNotation(index int) string {
+	if index < 0 || index >= int(esbmr.NMoves) || index >= len(esbmr.Moves) {
+		return ""
+	}
+	var moves [8]int32
+	for i, m := range esbmr.Moves[index] {
+		moves[i] = int32(m)
+	}
+	return FormatMove(Position(esbmr.Pos), moves)
+}
+
+// ParseMove parses a checker play written as FormatMove writes it, with
+// points numbered from the player moving, into the from and to list of
+// MoveEntry.Moves. Moves spanning several dice, such as 24/18 with 4-2,
+// are split into one hop per die, using the position before the play to
+// avoid points the opponent holds. Hit marks are accepted and ignored.
+func ParseMove(pos Position, dice [2]int32, notation string) ([8]int32, error) {
+	var moves [8]int32
+	for i := range moves {
+		moves[i] = MOVE_END
+	}
+
+	var segments [][2]int
+	for _, token := range strings.Fields(notation) {
+		count := 1
+		if open := strings.IndexByte(token, '('); open >= 0 {
+			if !strings.HasSuffix(token, ")") {
+				return moves, fmt.Errorf("move %q: unbalanced parenthesis", token)
+			}
+			n, err := strconv.Atoi(token[open+1 : len(token)-1])
+			if err != nil || n < 1 || n > 4 {
+				return moves, fmt.Errorf("move %q: invalid repeat count", token)
+			}
+			count = n
+			token = token[:open]
+		}
+
+		fields := strings.Split(token, "/")
+		if len(fields) < 2 {
+			return moves, fmt.Errorf("move %q: want from/to", token)
+		}
+		points := make([]int, len(fields))
+		for i, field := range fields {
+			field = strings.TrimRight(field, "*")
+			switch strings.ToLower(field) {
+			case "bar":
+				points[i] = MOVE_BAR
+			case "off":
+				points[i] = MOVE_OFF
+			default:
+				n, err := strconv.Atoi(field)
+				if err != nil || n < 1 || n > 24 {
+					return moves, fmt.Errorf("move %q: invalid point %q", token, field)
+				}
+				points[i] = n
+			}
+		}
+		for ; count > 0; count-- {
+			for i := 0; i+1 < len(points); i++ {
+				segments = append(segments, [2]int{points[i], points[i+1]})
+			}
+		}
+	}
+	if len(segments) == 0 {
+		return moves, nil
+	}
+
+	var available []int
+	if dice[0] < 1 || dice[0] > 6 || dice[1] < 1 || dice[1] > 6 {
+		return moves, fmt.Errorf("invalid dice %d%d", dice[0], dice[1])
+	}
+	if dice[0] == dice[1] {
+		available = []int{int(dice[0]), int(dice[0]), int(dice[0]), int(dice[0])}
+	} else {
+		available = []int{int(dice[0]), int(dice[1])}
+	}
+
+	hops, ok := splitSegments(pos, segments, available)
+	if !ok {
+		return moves, fmt.Errorf("move %q cannot be played with %d%d", notation, dice[0], dice[1])
+	}
+	for i, h := range hops {
+		moves[2*i] = int32(h.from)
+		moves[2*i+1] = int32(h.to)
+	}
+	return moves, nil
+}
+
+// splitSegments splits each segment into hops of one die, trying every
+// order of the remaining dice, and returns the hops of the first split
+// using each die at most once.
+func splitSegments(pos Position, segments [][2]int, dice []int) ([]hop, bool) {
+	if len(segments) == 0 {
+		return nil, true
+	}
+	from, to := segments[0][0], segments[0][1]
+	target := to
+	if to == MOVE_OFF {
+		target = 0
+	}
+	if from <= target {
+		return nil, false
+	}
+
+	var try func(point int, dice []int, hops []hop) ([]hop, bool)
+	try = func(point int, dice []int, hops []hop) ([]hop, bool) {
+		if point == target {
+			rest, ok := splitSegments(pos, segments[1:], dice)
+			if !ok {
+				return nil, false
+			}
+			return append(hops, rest...), true
+		}
+		for i, die := range dice {
+			if i > 0 && die == dice[i-1] {
+				continue
+			}
+			next := point - die
+			if next < target && to == MOVE_OFF {
+				// A larger die may bear off from a lower point.
+				next = target
+			}
+			if next < target || (next > 0 && pos[next] <= -2) {
+				continue
+			}
+			dest := next
+			if dest == 0 {
+				dest = MOVE_OFF
+			}
+			rest := append(append([]int{}, dice[:i]...), dice[i+1:]...)
+			if result, ok := try(next, rest, append(hops, hop{from: point, to: dest})); ok {
+				return result, true
+			}
+		}
+		return nil, false
+	}
+	return try(from, dice, nil)
+}
@@ -0,0 +1,116 @@
+package xgfile
+
+import "testing"
+
+func TestMoveNotation(t *testing.T) {
+	hitPosition := StartingPosition
+	hitPosition[POS_BAR] = 1
+	hitPosition[24] = 1
+	hitPosition[22] = -1
+	hitPosition[10] = -1
+	hitPosition[19] = -4
+	bearOff := Position{6: 2, 5: 3, 3: 1, 10: -2}
+
+	for _, tc := range []struct {
+		name     string
+		pos      Position
+		dice     [2]int32
+		notation string
+		moves    []int32
+	}{
+		{"single", StartingPosition, [2]int32{3, 1}, "8/5 6/5", []int32{8, 5, 6, 5}},
+		{"merged hops", StartingPosition, [2]int32{4, 2}, "24/18", []int32{24, 20, 20, 18}},
+		{"enter and hit", hitPosition, [2]int32{3, 5}, "bar/22* 13/8", []int32{MOVE_BAR, 22, 13, 8}},
+		{"hit on the way", hitPosition, [2]int32{3, 2}, "13/10*/8", []int32{13, 10, 10, 8}},
+		{"grouped double", StartingPosition, [2]int32{3, 3}, "8/5(2) 6/3(2)", []int32{8, 5, 8, 5, 6, 3, 6, 3}},
+		{"two groups", StartingPosition, [2]int32{6, 6}, "24/18(2) 13/7(2)", []int32{24, 18, 24, 18, 13, 7, 13, 7}},
+		{"bear off", bearOff, [2]int32{6, 6}, "6/off(2) 5/off(2)", []int32{6, MOVE_OFF, 6, MOVE_OFF, 5, MOVE_OFF, 5, MOVE_OFF}},
+		{"bear off with a larger die", bearOff, [2]int32{6, 4}, "5/1 3/off", []int32{5, 1, 3, MOVE_OFF}},
+		// The opponent moves in a position seen from their side.
+		{"opponent enters", testRacePosition.Flip(), [2]int32{4, 4}, "bar/17(2)", []int32{MOVE_BAR, 21, 21, 17, MOVE_BAR, 21, 21, 17}},
+		{"no move", StartingPosition, [2]int32{6, 6}, "", nil},
+	} {
+		moves, err := ParseMove(tc.pos, tc.dice, tc.notation)
+		if err != nil {
+			t.Errorf("%s: ParseMove(%q): %v", tc.name, tc.notation, err)
+			continue
+		}
+		var want [8]int32
+		for i := range want {
+			want[i] = MOVE_END
+		}
+		copy(want[:], tc.moves)
+		if moves != want {
+			t.Errorf("%s: ParseMove(%q) = %v, want %v", tc.name, tc.notation, moves, want)
+		}
+		if got := FormatMove(tc.pos, moves); got != tc.notation {
+			t.Errorf("%s: FormatMove(%v) = %q, want %q", tc.name, moves, got, tc.notation)
+		}
+	}
+}
+
+func TestFormatMoveInput(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		moves [8]int32
+		want  string
+	}{
+		// XG also writes 0 for a checker borne off.
+		{"zero is off", [8]int32{6, 0, 6, 0, -1, -1, -1, -1}, "6/off(2)"},
+		{"ends at the first MOVE_END", [8]int32{13, 7, -1, -1, 8, 2, -1, -1}, "13/7"},
+		{"highest point first", [8]int32{6, 5, 13, 10, 24, 23, 8, 5}, "24/23 13/10 8/5 6/5"},
+	} {
+		if got := FormatMove(StartingPosition, tc.moves); got != tc.want {
+			t.Errorf("%s: FormatMove(%v) = %q, want %q", tc.name, tc.moves, got, tc.want)
+		}
+	}
+}
+
+func TestParseMoveErrors(t *testing.T) {
+	blocked := StartingPosition
+	blocked[18] = -2
+	blocked[20] = -2
+	blocked[12] = 0
+	blocked[17] = 0
+	for _, tc := range []struct {
+		name     string
+		pos      Position
+		dice     [2]int32
+		notation string
+	}{
+		{"dash", StartingPosition, [2]int32{6, 1}, "13-7 8-7"},
+		{"no destination", StartingPosition, [2]int32{6, 1}, "13/"},
+		{"point 25", StartingPosition, [2]int32{6, 1}, "25/19"},
+		{"not a point", StartingPosition, [2]int32{6, 1}, "x/7"},
+		{"unbalanced parenthesis", StartingPosition, [2]int32{3, 3}, "8/5(2"},
+		{"repeat count", StartingPosition, [2]int32{3, 3}, "8/5(5)"},
+		{"backwards", StartingPosition, [2]int32{3, 1}, "5/8"},
+		{"invalid dice", StartingPosition, [2]int32{0, 3}, "8/5"},
+		{"wrong distance", StartingPosition, [2]int32{5, 3}, "24/18"},
+		{"die used twice", StartingPosition, [2]int32{6, 1}, "13/7 8/2"},
+		{"blocked", blocked, [2]int32{6, 4}, "24/14"},
+		{"too many hops", StartingPosition, [2]int32{6, 6}, "24/18(2) 13/7(3)"},
+	} {
+		if moves, err := ParseMove(tc.pos, tc.dice, tc.notation); err == nil {
+			t.Errorf("%s: ParseMove(%q) = %v, want an error", tc.name, tc.notation, moves)
+		}
+	}
+}
+
+func TestMoveEntryNotation(t *testing.T) {
+	me := &MoveEntry{
+		ActiveP:   -1,
+		PositionI: StartingPosition,
+		Moves:     [8]int32{24, 18, 13, 11, -1, -1, -1, -1},
+	}
+	if got := me.Notation(); got != "24/18 13/11" {
+		t.Errorf("Notation() = %q", got)
+	}
+	if got := me.NotationFor(-1); got != "24/18 13/11" {
+		t.Errorf("NotationFor(-1) = %q", got)
+	}
+	// Numbered from the other player.
+	if got := me.NotationFor(1); got != "1/7 12/14" {
+		t.Errorf("NotationFor(1) = %q", got)
+	}
+}
@@ -0,0 +1,132 @@
+package xgfile
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Position is a backgammon position in XG's 26 point encoding, seen from
+// one player, "the player", facing "the opponent". Index 1 to 24 are the
+// points numbered from the player's home board, index 25 is the player's
+// bar and index 0 the opponent's bar. The player's checkers are counted
+// positive, the opponent's negative. Checkers not on the board have been
+// borne off.
+//
+// The player moves from 24 down to 1, the opponent from 1 up to 24.
+// XG stores positions seen from the player on roll, ActiveP.
+type Position [26]int8
+
+const (
+	POS_OPP_BAR = 0
+	POS_BAR     = 25
+)
+
+// CHECKERS_PER_SIDE is the number of checkers each player starts with.
+const CHECKERS_PER_SIDE = 15
+
+// StartingPosition is the initial position of a game.
+var StartingPosition = Position{
+	6: 5, 8: 3, 13: 5, 24: 2,
+	1: -2, 12: -5, 17: -3, 19: -5,
+}
+
+// Bar returns the number of the player's checkers on the bar.
+func (p Position) Bar() int {
+	return int(p[POS_BAR])
+}
+
+// OppBar returns the number of the opponent's checkers on the bar.
+func (p Position) OppBar() int {
+	return -int(p[POS_OPP_BAR])
+}
+
+// Off returns the number of checkers the player has borne off.
+func (p Position) Off() int {
+	player, _ := p.onBoard()
+	return CHECKERS_PER_SIDE - player
+}
+
+// OppOff returns the number of checkers the opponent has borne off.
+func (p Position) OppOff() int {
+	_, opponent := p.onBoard()
+	return CHECKERS_PER_SIDE - opponent
+}
+
+// onBoard counts the checkers of each side on the points and the bar.
+func (p Position) onBoard() (player, opponent int) {
+	for _, n := range p {
+		if n > 0 {
+			player += int(n)
+		} else {
+			opponent -= int(n)
+		}
+	}
+	return player, opponent
+}
+
+// PipCount returns the pip count of the player and of the opponent.
+// Checkers on the bar count 25 pips.
+func (p Position) PipCount() (player, opponent int) {
+	for i, n := range p {
+		if n > 0 {
+			player += int(n) * i
+		} else {
+			opponent -= int(n) * (25 - i)
+		}
+	}
+	return player, opponent
+}
+
+// Flip returns the position seen from the opponent.
+func (p Position) Flip() Position {
+	var q Position
+	for i, n := range p {
+		q[25-i] = -n
+	}
+	return q
+}
+
+// Perspective returns the position seen by player, 1 or -1 as in
+// ActiveP, when p is seen by activeP.
+func (p Position) Perspective(activeP, player int32) Position {
+	if activeP == player {
+		return p
+	}
+	return p.Flip()
+}
+
+// Validate checks that neither side has more than CHECKERS_PER_SIDE
+// checkers and that each bar only holds its own side's checkers. The
+// encoding cannot give a point to both sides, so the bars are the only
+// places where checkers can be misplaced.
+func (p Position) Validate() error {
+	if p[POS_BAR] < 0 {
+		return errors.New("opponent checkers on the player's bar")
+	}
+	if p[POS_OPP_BAR] > 0 {
+		return errors.New("player checkers on the opponent's bar")
+	}
+	player, opponent := p.onBoard()
+	if player > CHECKERS_PER_SIDE {
+		return fmt.Errorf("player has %d checkers", player)
+	}
+	if opponent > CHECKERS_PER_SIDE {
+		return fmt.Errorf("opponent has %d checkers", opponent)
+	}
+	return nil
+}
+
+// StartPosition returns the position before the move.
+func (me *MoveEntry) StartPosition() Position {
+	return Position(me.PositionI)
+}
+
+// EndPosition returns the position after the move.
+func (me *MoveEntry) EndPosition() Position {
+	return Position(me.PositionEnd)
+}
+
+// CubePosition returns the position of the cube decision.
+func (ce *CubeEntry) CubePosition() Position {
+	return Position(ce.Position)
+}
@@ -0,0 +1,83 @@
+package xgfile
+
+import "testing"
+
+// testRacePosition has checkers on both bars and some borne off.
+var testRacePosition = Position{
+	POS_BAR: 1, 24: 1, 6: 4, 3: 3, 1: 2,
+	POS_OPP_BAR: -2, 2: -1, 20: -5, 23: -3,
+}
+
+func TestPositionCounts(t *testing.T) {
+	for _, tc := range []struct {
+		name                     string
+		pos                      Position
+		pips, oppPips            int
+		bar, oppBar, off, oppOff int
+	}{
+		{"start", StartingPosition, 167, 167, 0, 0, 0, 0},
+		{"race", testRacePosition, 25 + 24 + 24 + 9 + 2, 50 + 23 + 25 + 6, 1, 2, 4, 4},
+		{"empty", Position{}, 0, 0, 0, 0, 15, 15},
+	} {
+		pips, oppPips := tc.pos.PipCount()
+		if pips != tc.pips || oppPips != tc.oppPips {
+			t.Errorf("%s: pip count %d/%d, want %d/%d", tc.name, pips, oppPips, tc.pips, tc.oppPips)
+		}
+		if got := [4]int{tc.pos.Bar(), tc.pos.OppBar(), tc.pos.Off(), tc.pos.OppOff()}; got != [4]int{tc.bar, tc.oppBar, tc.off, tc.oppOff} {
+			t.Errorf("%s: bar, opponent bar, off, opponent off = %v", tc.name, got)
+		}
+	}
+}
+
+func TestPositionFlip(t *testing.T) {
+	if got := StartingPosition.Flip(); got != StartingPosition {
+		t.Errorf("starting position flipped to %v", got)
+	}
+	flipped := testRacePosition.Flip()
+	if flipped[POS_OPP_BAR] != -1 || flipped[1] != -1 || flipped[POS_BAR] != 2 || flipped[23] != 1 || flipped[5] != 5 {
+		t.Errorf("race position flipped to %v", flipped)
+	}
+	if pips, oppPips := flipped.PipCount(); pips != 104 || oppPips != 84 {
+		t.Errorf("flipped pip count %d/%d, want the sides swapped", pips, oppPips)
+	}
+	if got := flipped.Flip(); got != testRacePosition {
+		t.Errorf("flipping twice gave %v", got)
+	}
+
+	for _, tc := range []struct {
+		activeP, player int32
+		want            Position
+	}{
+		{1, 1, testRacePosition},
+		{-1, -1, testRacePosition},
+		{1, -1, flipped},
+		{-1, 1, flipped},
+	} {
+		if got := testRacePosition.Perspective(tc.activeP, tc.player); got != tc.want {
+			t.Errorf("Perspective(%d, %d) = %v, want %v", tc.activeP, tc.player, got, tc.want)
+		}
+	}
+}
+
+func TestPositionValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		pos   Position
+		valid bool
+	}{
+		{"start", StartingPosition, true},
+		{"race", testRacePosition, true},
+		{"all borne off", Position{}, true},
+		{"16 player checkers", Position{6: 16}, false},
+		{"16 opponent checkers", Position{19: -16}, false},
+		{"16 player checkers with the bar", Position{POS_BAR: 2, 6: 14}, false},
+		// Points hold one side by construction, so the bars are where
+		// both sides can meet: each only takes its own side's checkers.
+		{"opponent on the player's bar", Position{POS_BAR: -1}, false},
+		{"player on the opponent's bar", Position{POS_OPP_BAR: 1}, false},
+	} {
+		if err := tc.pos.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tc.name, err, tc.valid)
+		}
+	}
+}
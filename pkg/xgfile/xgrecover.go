@@ -0,0 +1,225 @@
+package xgfile
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+)
+
+// RecoveredMatch holds the records salvaged from a damaged game file.
+// Match is nil when the match header could not be decoded. Each game
+// starts with its HeaderGameEntry record, unless that record was lost.
+type RecoveredMatch struct {
+	Match *HeaderMatchEntry
+	Games [][]*GameFileRecord
+}
+
+// RecoverGameFile decodes every record of a game file that can be decoded
+// and returns them grouped by game, with one diagnostic per skipped
+// record. Records have a fixed size, so decoding resumes on the next
+// record boundary after a failure. Unlike NewGameFileReader, the "DMLI"
+// magic is not required.
+func RecoverGameFile(stream io.Reader) (*RecoveredMatch, []error) {
+	var diagnostics []error
+	rm := &RecoveredMatch{}
+	gr := &GameFileReader{stream: stream, version: -1}
+	var game []*GameFileRecord
+	for {
+		gfr, err := gr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			diagnostics = append(diagnostics, err)
+			if errors.Is(err, ErrTruncated) {
+				break
+			}
+			continue
+		}
+
+		switch rec := gfr.Record.(type) {
+		case *HeaderMatchEntry:
+			if rm.Match == nil {
+				rm.Match = rec
+				continue
+			}
+		case *HeaderGameEntry:
+			if game != nil {
+				rm.Games = append(rm.Games, game)
+			}
+			game = nil
+		}
+		game = append(game, gfr)
+	}
+	if game != nil {
+		rm.Games = append(rm.Games, game)
+	}
+	return rm, diagnostics
+}
+
+// RecoverGameFile salvages the records of an XG_GAMEFILE segment.
+func (s *Segment) RecoverGameFile() (*RecoveredMatch, []error) {
+	if s.Type != XG_GAMEFILE {
+		return nil, []error{errors.New("segment is not an XG gamefile")}
+	}
+	if _, err := s.Reader.Seek(0, io.SeekStart); err != nil {
+		return nil, []error{err}
+	}
+	return RecoverGameFile(s.Reader)
+}
+
+// salvageEntry is called when an archive entry cannot be read. In recovery
+// mode the failure is recorded and the entry is read leniently: stored
+// data is kept as is and compressed data is inflated as far as it goes.
+// A nil segment is returned when nothing could be read.
+func (imp *Import) salvageEntry(za *ZlibArchive, filerec FileRecord, segmentType int, cause error) (*Segment, error) {
+	if !imp.Recover {
+		return nil, cause
+	}
+	imp.Diagnostics = append(imp.Diagnostics, cause)
+
+	// Clip the entry to the archive data.
+	start := int64(filerec.Start)
+	end := start + int64(filerec.CSize)
+	if start < 0 || filerec.CSize <= 0 || start >= int64(za.ArcRec.ArchiveSize) {
+		return nil, nil
+	}
+	if end > int64(za.ArcRec.ArchiveSize) || end < start {
+		end = int64(za.ArcRec.ArchiveSize)
+	}
+	data := make([]byte, end-start)
+	n, err := za.Stream.ReadAt(data, za.StartOfArcData+start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	data = data[:n]
+
+	if filerec.Compressed {
+		maxSize := int64(filerec.OSize)
+		if maxSize <= 0 || maxSize > int64(len(data))*maxZlibRatio {
+			maxSize = int64(len(data)) * maxZlibRatio
+		}
+		data, _, _ = inflatePartial(data, maxSize)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return imp.newDataSegment(segmentType, data)
+}
+
+// scanSegments finds the archive entries between start and end when the
+// archive registry cannot be read, by looking for zlib streams. Each
+// stream becomes a segment typed from its contents. Stored entries
+// cannot be told apart from other data and are not found.
+func (imp *Import) scanSegments(stream io.ReaderAt, start, end int64) (segments []*Segment, err error) {
+	if end <= start {
+		return nil, nil
+	}
+	data := make([]byte, end-start)
+	n, err := stream.ReadAt(data, start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	data = data[:n]
+
+	sawGameHdr := false
+	for pos := 0; pos+2 <= len(data); {
+		if !isZlibHeader(data[pos], data[pos+1]) {
+			pos++
+			continue
+		}
+		block, consumed, err := inflatePartial(data[pos:], int64(len(data)-pos)*maxZlibRatio)
+		segmentType := classifySegment(block)
+		if err != nil && segmentType == XG_UNKNOWN {
+			// Most likely not a zlib stream at all.
+			pos++
+			continue
+		}
+		if err != nil {
+			imp.Diagnostics = append(imp.Diagnostics,
+				withContext(newError(ErrDecompress, int64(pos), err), "archive", start, -1))
+		}
+		if segmentType == XG_GAMEHDR {
+			// Both start with the match header; a short game file
+			// is one cut off after its first record.
+			if sawGameHdr {
+				segmentType = XG_GAMEFILE
+			}
+			sawGameHdr = true
+		}
+		if len(block) > 0 {
+			segment, err := imp.newDataSegment(segmentType, block)
+			if err != nil {
+				for _, segment := range segments {
+					segment.Close()
+				}
+				return nil, err
+			}
+			segments = append(segments, segment)
+		}
+		pos += consumed
+	}
+	return segments, nil
+}
+
+// isZlibHeader reports whether cmf and flg start a zlib stream: deflate
+// with a window of at most 32K, no preset dictionary and a valid check
+// value.
+func isZlibHeader(cmf, flg byte) bool {
+	return cmf&0x0f == 8 && cmf>>4 <= 7 && flg&0x20 == 0 &&
+		(uint16(cmf)<<8|uint16(flg))%31 == 0
+}
+
+// inflatePartial inflates the zlib stream at the start of data, at most
+// maxSize bytes of it. It returns what could be inflated, the number of
+// bytes of data the stream used and the error that stopped it, if any.
+func inflatePartial(data []byte, maxSize int64) ([]byte, int, error) {
+	// bytes.Reader implements io.ByteReader, so the inflater reads no
+	// further than the end of the stream.
+	src := bytes.NewReader(data)
+	decomp, err := zlib.NewReader(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer decomp.Close()
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, io.LimitReader(decomp, maxSize))
+	return buf.Bytes(), len(data) - src.Len(), err
+}
+
+// classifySegment guesses the type of an archive entry from its contents.
+func classifySegment(data []byte) int {
+	switch {
+	case len(data) == 0:
+		return XG_UNKNOWN
+	case len(data) >= XG_GAMEHDR_LEN+4 && string(data[XG_GAMEHDR_LEN:XG_GAMEHDR_LEN+4]) == "DMLI":
+		if len(data) > XG_GAMEFILEREC_LEN {
+			return XG_GAMEFILE
+		}
+		return XG_GAMEHDR
+	case data[0] == '{':
+		return XG_COMMENT
+	case len(data)%FILEREC_LEN == 0 && isRegistry(data):
+		return ZLIBARC_IDX
+	case len(data)%XG_ROLLOUTREC_LEN == 0:
+		return XG_ROLLOUTS
+	}
+	return XG_UNKNOWN
+}
+
+// isRegistry reports whether data decodes as file records naming XG
+// segments.
+func isRegistry(data []byte) bool {
+	for i := 0; i < len(data); i += FILEREC_LEN {
+		var fr FileRecord
+		if err := fr.FromStream(bytes.NewReader(data[i : i+FILEREC_LEN])); err != nil {
+			return false
+		}
+		if !strings.HasPrefix(fr.Name, "temp.") {
+			return false
+		}
+	}
+	return true
+}
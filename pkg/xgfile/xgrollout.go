@@ -0,0 +1,88 @@
+package xgfile
+
+import (
+	"errors"
+	"io"
+)
+
+// RolloutFileReader iterates over the rollout contexts of a temp.xgr
+// rollout file segment.
+type RolloutFileReader struct {
+	stream io.Reader
+	index  int
+}
+
+// NewRolloutFileReader returns a reader positioned on the first rollout
+// context of the stream.
+func NewRolloutFileReader(stream io.Reader) *RolloutFileReader {
+	return &RolloutFileReader{stream: stream}
+}
+
+// Next returns the next rollout record, or io.EOF once every record has
+// been read.
+func (rr *RolloutFileReader) Next() (*RolloutFileRecord, error) {
+	index := rr.index
+	rr.index++
+	rfr := &RolloutFileRecord{}
+	if err := rfr.FromStream(rr.stream); err != nil {
+		return nil, withContext(err, "temp.xgr", int64(index)*XG_ROLLOUTREC_LEN, index)
+	}
+	return rfr, nil
+}
+
+// RolloutFile holds every rollout context of a rollout file, in the order
+// referenced by MoveEntry.RolloutIndexM and CubeEntry.RolloutIndexD.
+type RolloutFile struct {
+	Rollouts []RolloutContextEntry
+}
+
+// ReadRolloutFile decodes all rollout contexts from the stream.
+func ReadRolloutFile(stream io.Reader) (*RolloutFile, error) {
+	rf := &RolloutFile{}
+	rr := NewRolloutFileReader(stream)
+	for {
+		rfr, err := rr.Next()
+		if err == io.EOF {
+			return rf, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rf.Rollouts = append(rf.Rollouts, rfr.Record)
+	}
+}
+
+// RolloutFile decodes the rollout contexts of an XG_ROLLOUTS segment.
+func (s *Segment) RolloutFile() (*RolloutFile, error) {
+	if s.Type != XG_ROLLOUTS {
+		return nil, errors.New("segment is not an XG rollout file")
+	}
+	if _, err := s.Reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return ReadRolloutFile(s.Reader)
+}
+
+// Rollout returns the rollout context at the given index, or nil when the
+// index does not refer to a rollout. Records use -1 for "not rolled out".
+func (rf *RolloutFile) Rollout(index int32) *RolloutContextEntry {
+	if index < 0 || int(index) >= len(rf.Rollouts) {
+		return nil
+	}
+	return &rf.Rollouts[index]
+}
+
+// MoveRollout returns the rollout of the candidate play at the given
+// position of the move's analysis, or nil if it was not rolled out.
+func (rf *RolloutFile) MoveRollout(me *MoveEntry, candidate int) *RolloutContextEntry {
+	if candidate < 0 || candidate >= len(me.RolloutIndexM) {
+		return nil
+	}
+	return rf.Rollout(me.RolloutIndexM[candidate])
+}
+
+// CubeRollout returns the rollout of the cube decision, or nil if it was
+// not rolled out.
+func (rf *RolloutFile) CubeRollout(ce *CubeEntry) *RolloutContextEntry {
+	return rf.Rollout(ce.RolloutIndexD)
+}
@@ -1,12 +1,24 @@
 package xgfile
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"strings"
+	"time"
 )
 
+// matchHeaderMagic is "DMLI" read as a little endian integer.
+const matchHeaderMagic = 0x494C4D44
+
+// delphiDateLayout is the layout used for dates decoded from Delphi
+// TDateTime values.
+const delphiDateLayout = "2006-01-02 15:04:05"
+
 type GameDataFormatHdrRecord struct {
 	MagicNumber     string
 	HeaderVersion   int32
@@ -20,42 +32,132 @@ type GameDataFormatHdrRecord struct {
 	Comments        string
 }
 
+// GDF_HDR_LEN is the size of the GameDataFormatHdrRecord at the start of an
+// .xg file.
+const GDF_HDR_LEN = 8232
+
 func (hdr *GameDataFormatHdrRecord) FromStream(stream io.Reader) error {
-	var unpackedData [8232]byte
-	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
-		return err
+	var unpackedData [GDF_HDR_LEN]byte
+	if n, err := io.ReadFull(stream, unpackedData[:]); err != nil {
+		return newError(ErrTruncated, int64(n), err)
 	}
 
-	hdr.MagicNumber = string(unpackedData[0:4])
+	// The magic number is the little endian integer 'HMGR', so its bytes
+	// are stored in reverse order.
+	hdr.MagicNumber = string([]byte{unpackedData[3], unpackedData[2], unpackedData[1], unpackedData[0]})
+	if hdr.MagicNumber != "HMGR" {
+		return newError(ErrBadMagic, 0, nil)
+	}
 	hdr.HeaderVersion = int32(binary.LittleEndian.Uint32(unpackedData[4:8]))
-	if hdr.MagicNumber != "HMGR" || hdr.HeaderVersion != 1 {
-		return errors.New("invalid magic number or header version")
+	if hdr.HeaderVersion != 1 {
+		return newError(ErrUnsupportedVersion, 4, fmt.Errorf("header version %d", hdr.HeaderVersion))
 	}
 
 	hdr.HeaderSize = int32(binary.LittleEndian.Uint32(unpackedData[8:12]))
 	hdr.ThumbnailOffset = int64(binary.LittleEndian.Uint64(unpackedData[12:20]))
 	hdr.ThumbnailSize = int32(binary.LittleEndian.Uint32(unpackedData[20:24]))
+	if hdr.HeaderSize < GDF_HDR_LEN || hdr.ThumbnailOffset < 0 || hdr.ThumbnailSize < 0 {
+		return newError(ErrCorrupt, 8, errors.New("header or thumbnail size"))
+	}
 
 	guid := unpackedData[24:40]
 	hdr.GameGUID = formatGUID(guid)
 
-	hdr.GameName = UTF16IntArrayToStr(unpackUint16Array(unpackedData[40:2064]))
-	hdr.SaveName = UTF16IntArrayToStr(unpackUint16Array(unpackedData[2064:4088]))
-	hdr.LevelName = UTF16IntArrayToStr(unpackUint16Array(unpackedData[4088:6112]))
-	hdr.Comments = UTF16IntArrayToStr(unpackUint16Array(unpackedData[6112:8136]))
+	hdr.GameName = UTF16IntArrayToStr(unpackUint16Array(unpackedData[40:2088]))
+	hdr.SaveName = UTF16IntArrayToStr(unpackUint16Array(unpackedData[2088:4136]))
+	hdr.LevelName = UTF16IntArrayToStr(unpackUint16Array(unpackedData[4136:6184]))
+	hdr.Comments = UTF16IntArrayToStr(unpackUint16Array(unpackedData[6184:8232]))
 
 	return nil
 }
 
+func (hdr *GameDataFormatHdrRecord) MarshalBinary() ([]byte, error) {
+	guid, err := parseGUID(hdr.GameGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	packedData := make([]byte, GDF_HDR_LEN)
+	copy(packedData[0:4], "RGMH")
+	binary.LittleEndian.PutUint32(packedData[4:8], uint32(hdr.HeaderVersion))
+	binary.LittleEndian.PutUint32(packedData[8:12], uint32(hdr.HeaderSize))
+	binary.LittleEndian.PutUint64(packedData[12:20], uint64(hdr.ThumbnailOffset))
+	binary.LittleEndian.PutUint32(packedData[20:24], uint32(hdr.ThumbnailSize))
+	copy(packedData[24:40], guid)
+	packUint16Array(packedData[40:2088], StrToUTF16IntArray(hdr.GameName, 1024))
+	packUint16Array(packedData[2088:4136], StrToUTF16IntArray(hdr.SaveName, 1024))
+	packUint16Array(packedData[4136:6184], StrToUTF16IntArray(hdr.LevelName, 1024))
+	packUint16Array(packedData[6184:8232], StrToUTF16IntArray(hdr.Comments, 1024))
+
+	return packedData, nil
+}
+
+// formatGUID formats a Windows GUID: the first three groups are little
+// endian integers, the last two are byte sequences.
 func formatGUID(guid []byte) string {
-	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x",
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
 		binary.LittleEndian.Uint32(guid[0:4]),
 		binary.LittleEndian.Uint16(guid[4:6]),
 		binary.LittleEndian.Uint16(guid[6:8]),
-		binary.LittleEndian.Uint16(guid[8:10]),
+		guid[8:10],
 		guid[10:16])
 }
 
+// parseGUID is the inverse of formatGUID. An empty string is the nil GUID.
+func parseGUID(str string) ([]byte, error) {
+	guid := make([]byte, 16)
+	if str == "" {
+		return guid, nil
+	}
+	raw, err := hex.DecodeString(strings.ReplaceAll(str, "-", ""))
+	if err != nil || len(raw) != 16 {
+		return nil, fmt.Errorf("invalid GUID %q", str)
+	}
+	binary.LittleEndian.PutUint32(guid[0:4], binary.BigEndian.Uint32(raw[0:4]))
+	binary.LittleEndian.PutUint16(guid[4:6], binary.BigEndian.Uint16(raw[4:6]))
+	binary.LittleEndian.PutUint16(guid[6:8], binary.BigEndian.Uint16(raw[6:8]))
+	copy(guid[8:16], raw[8:16])
+	return guid, nil
+}
+
+func copyInt8(dst []int8, src []byte) {
+	for i := range dst {
+		dst[i] = int8(src[i])
+	}
+}
+
+func putInt8(dst []byte, src []int8) {
+	for i := range src {
+		dst[i] = byte(src[i])
+	}
+}
+
+func boolToByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// parseDelphiDate converts a date decoded from a Delphi TDateTime back to
+// its float value. The empty string is day 0.
+func parseDelphiDate(date string) (float64, error) {
+	if date == "" {
+		return 0, nil
+	}
+	t, err := time.Parse(delphiDateLayout, date)
+	if err != nil {
+		return 0, err
+	}
+	return TimeToDelphiDateTime(t), nil
+}
+
+func packUint16Array(dst []byte, intArray []uint16) {
+	for i, intval := range intArray {
+		binary.LittleEndian.PutUint16(dst[i*2:i*2+2], intval)
+	}
+}
+
 func unpackUint16Array(data []byte) []uint16 {
 	var result []uint16
 	for i := 0; i < len(data); i += 2 {
@@ -93,6 +195,21 @@ func (tsr *TimeSettingRecord) FromStream(stream io.Reader) error {
 	return nil
 }
 
+func (tsr *TimeSettingRecord) MarshalBinary() ([]byte, error) {
+	packedData := make([]byte, 32)
+
+	binary.LittleEndian.PutUint32(packedData[0:4], uint32(tsr.ClockType))
+	packedData[4] = boolToByte(tsr.PerGame)
+	binary.LittleEndian.PutUint32(packedData[8:12], uint32(tsr.Time1))
+	binary.LittleEndian.PutUint32(packedData[12:16], uint32(tsr.Time2))
+	binary.LittleEndian.PutUint32(packedData[16:20], uint32(tsr.Penalty))
+	binary.LittleEndian.PutUint32(packedData[20:24], uint32(tsr.TimeLeft1))
+	binary.LittleEndian.PutUint32(packedData[24:28], uint32(tsr.TimeLeft2))
+	binary.LittleEndian.PutUint32(packedData[28:32], uint32(tsr.PenaltyMoney))
+
+	return packedData, nil
+}
+
 type EvalLevelRecord struct {
 	Level    int16
 	IsDouble bool
@@ -110,9 +227,23 @@ func (elr *EvalLevelRecord) FromStream(stream io.Reader) error {
 	return nil
 }
 
+func (elr *EvalLevelRecord) MarshalBinary() ([]byte, error) {
+	packedData := make([]byte, 4)
+
+	binary.LittleEndian.PutUint16(packedData[0:2], uint16(elr.Level))
+	packedData[2] = boolToByte(elr.IsDouble)
+
+	return packedData, nil
+}
+
+// XG_BESTMOVEREC_LEN is the size of an EngineStructBestMoveRecord: a 68 byte
+// prelude, 32 played positions, 32 move lists, 32 eval levels, 32
+// evaluations and 4 trailing flag bytes.
+const XG_BESTMOVEREC_LEN = 2184
+
 type EngineStructBestMoveRecord struct {
 	Pos       [26]int8
-	Dice      [2]int8
+	Dice      [2]int32
 	Level     int32
 	Score     [2]int32
 	Cube      int32
@@ -131,62 +262,83 @@ type EngineStructBestMoveRecord struct {
 }
 
 func (esbmr *EngineStructBestMoveRecord) FromStream(stream io.Reader) error {
-	var unpackedData [68]byte
+	var unpackedData [XG_BESTMOVEREC_LEN]byte
 	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
 		return err
 	}
 
-	copy(esbmr.Pos[:], unpackedData[0:26])
-	copy(esbmr.Dice[:], unpackedData[26:28])
-	esbmr.Level = int32(binary.LittleEndian.Uint32(unpackedData[28:32]))
-	esbmr.Score[0] = int32(binary.LittleEndian.Uint32(unpackedData[32:36]))
-	esbmr.Score[1] = int32(binary.LittleEndian.Uint32(unpackedData[36:40]))
-	esbmr.Cube = int32(binary.LittleEndian.Uint32(unpackedData[40:44]))
-	esbmr.CubePos = int32(binary.LittleEndian.Uint32(unpackedData[44:48]))
-	esbmr.Crawford = int32(binary.LittleEndian.Uint32(unpackedData[48:52]))
-	esbmr.Jacoby = int32(binary.LittleEndian.Uint32(unpackedData[52:56]))
-	esbmr.NMoves = int32(binary.LittleEndian.Uint32(unpackedData[56:60]))
+	// Prelude: Pos is followed by two bytes of padding so the integers
+	// that follow are 4-byte aligned.
+	copyInt8(esbmr.Pos[:], unpackedData[0:26])
+	esbmr.Dice[0] = int32(binary.LittleEndian.Uint32(unpackedData[28:32]))
+	esbmr.Dice[1] = int32(binary.LittleEndian.Uint32(unpackedData[32:36]))
+	esbmr.Level = int32(binary.LittleEndian.Uint32(unpackedData[36:40]))
+	esbmr.Score[0] = int32(binary.LittleEndian.Uint32(unpackedData[40:44]))
+	esbmr.Score[1] = int32(binary.LittleEndian.Uint32(unpackedData[44:48]))
+	esbmr.Cube = int32(binary.LittleEndian.Uint32(unpackedData[48:52]))
+	esbmr.CubePos = int32(binary.LittleEndian.Uint32(unpackedData[52:56]))
+	esbmr.Crawford = int32(binary.LittleEndian.Uint32(unpackedData[56:60]))
+	esbmr.Jacoby = int32(binary.LittleEndian.Uint32(unpackedData[60:64]))
+	esbmr.NMoves = int32(binary.LittleEndian.Uint32(unpackedData[64:68]))
 
 	for i := 0; i < 32; i++ {
-		if _, err := io.ReadFull(stream, esbmr.PosPlayed[i][:]); err != nil {
+		copyInt8(esbmr.PosPlayed[i][:], unpackedData[68+i*26:94+i*26])
+		copyInt8(esbmr.Moves[i][:], unpackedData[900+i*8:908+i*8])
+		if err := esbmr.EvalLevel[i].FromStream(bytes.NewReader(unpackedData[1156+i*4 : 1160+i*4])); err != nil {
 			return err
 		}
-	}
-
-	for i := 0; i < 32; i++ {
-		if _, err := io.ReadFull(stream, esbmr.Moves[i][:]); err != nil {
-			return err
+		for j := 0; j < 7; j++ {
+			esbmr.Eval[i][j] = math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[1284+i*28+j*4 : 1288+i*28+j*4]))
 		}
 	}
 
-	for i := 0; i < 32; i++ {
-		if err := esbmr.EvalLevel[i].FromStream(stream); err != nil {
-			return err
-		}
-	}
+	esbmr.Unused = int8(unpackedData[2180])
+	esbmr.Met = int8(unpackedData[2181])
+	esbmr.Choice0 = int8(unpackedData[2182])
+	esbmr.Choice3 = int8(unpackedData[2183])
+
+	return nil
+}
+
+func (esbmr *EngineStructBestMoveRecord) MarshalBinary() ([]byte, error) {
+	packedData := make([]byte, XG_BESTMOVEREC_LEN)
+
+	putInt8(packedData[0:26], esbmr.Pos[:])
+	binary.LittleEndian.PutUint32(packedData[28:32], uint32(esbmr.Dice[0]))
+	binary.LittleEndian.PutUint32(packedData[32:36], uint32(esbmr.Dice[1]))
+	binary.LittleEndian.PutUint32(packedData[36:40], uint32(esbmr.Level))
+	binary.LittleEndian.PutUint32(packedData[40:44], uint32(esbmr.Score[0]))
+	binary.LittleEndian.PutUint32(packedData[44:48], uint32(esbmr.Score[1]))
+	binary.LittleEndian.PutUint32(packedData[48:52], uint32(esbmr.Cube))
+	binary.LittleEndian.PutUint32(packedData[52:56], uint32(esbmr.CubePos))
+	binary.LittleEndian.PutUint32(packedData[56:60], uint32(esbmr.Crawford))
+	binary.LittleEndian.PutUint32(packedData[60:64], uint32(esbmr.Jacoby))
+	binary.LittleEndian.PutUint32(packedData[64:68], uint32(esbmr.NMoves))
 
 	for i := 0; i < 32; i++ {
-		var evalData [28]byte
-		if _, err := io.ReadFull(stream, evalData[:]); err != nil {
-			return err
+		putInt8(packedData[68+i*26:94+i*26], esbmr.PosPlayed[i][:])
+		putInt8(packedData[900+i*8:908+i*8], esbmr.Moves[i][:])
+		evalLevel, err := esbmr.EvalLevel[i].MarshalBinary()
+		if err != nil {
+			return nil, err
 		}
+		copy(packedData[1156+i*4:1160+i*4], evalLevel)
 		for j := 0; j < 7; j++ {
-			esbmr.Eval[i][j] = float32(binary.LittleEndian.Uint32(evalData[j*4 : (j+1)*4]))
+			binary.LittleEndian.PutUint32(packedData[1284+i*28+j*4:1288+i*28+j*4], math.Float32bits(esbmr.Eval[i][j]))
 		}
 	}
 
-	var finalData [4]byte
-	if _, err := io.ReadFull(stream, finalData[:]); err != nil {
-		return err
-	}
-	esbmr.Unused = int8(finalData[0])
-	esbmr.Met = int8(finalData[1])
-	esbmr.Choice0 = int8(finalData[2])
-	esbmr.Choice3 = int8(finalData[3])
+	packedData[2180] = byte(esbmr.Unused)
+	packedData[2181] = byte(esbmr.Met)
+	packedData[2182] = byte(esbmr.Choice0)
+	packedData[2183] = byte(esbmr.Choice3)
 
-	return nil
+	return packedData, nil
 }
 
+// XG_DOUBLEACTIONREC_LEN is the size of an EngineStructDoubleAction.
+const XG_DOUBLEACTIONREC_LEN = 132
+
 type EngineStructDoubleAction struct {
 	Pos           [26]int8
 	Level         int32
@@ -208,37 +360,68 @@ type EngineStructDoubleAction struct {
 }
 
 func (esdar *EngineStructDoubleAction) FromStream(stream io.Reader) error {
-	var unpackedData [132]byte
+	var unpackedData [XG_DOUBLEACTIONREC_LEN]byte
 	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
 		return err
 	}
 
-	copy(esdar.Pos[:], unpackedData[0:26])
-	esdar.Level = int32(binary.LittleEndian.Uint32(unpackedData[26:30]))
-	esdar.Score[0] = int32(binary.LittleEndian.Uint32(unpackedData[30:34]))
-	esdar.Score[1] = int32(binary.LittleEndian.Uint32(unpackedData[34:38]))
-	esdar.Cube = int32(binary.LittleEndian.Uint32(unpackedData[38:42]))
-	esdar.CubePos = int32(binary.LittleEndian.Uint32(unpackedData[42:46]))
-	esdar.Jacoby = int32(binary.LittleEndian.Uint32(unpackedData[46:50]))
-	esdar.Crawford = int32(binary.LittleEndian.Uint32(unpackedData[50:54]))
-	esdar.Met = int32(binary.LittleEndian.Uint32(unpackedData[54:58]))
-	esdar.FlagDouble = int32(binary.LittleEndian.Uint32(unpackedData[58:62]))
-	esdar.IsBeaver = int32(binary.LittleEndian.Uint32(unpackedData[62:66]))
+	// Pos is padded to 28 bytes; Jacoby through IsBeaver are SmallInts
+	// followed by two bytes of padding before the evaluation.
+	copyInt8(esdar.Pos[:], unpackedData[0:26])
+	esdar.Level = int32(binary.LittleEndian.Uint32(unpackedData[28:32]))
+	esdar.Score[0] = int32(binary.LittleEndian.Uint32(unpackedData[32:36]))
+	esdar.Score[1] = int32(binary.LittleEndian.Uint32(unpackedData[36:40]))
+	esdar.Cube = int32(binary.LittleEndian.Uint32(unpackedData[40:44]))
+	esdar.CubePos = int32(binary.LittleEndian.Uint32(unpackedData[44:48]))
+	esdar.Jacoby = int32(int16(binary.LittleEndian.Uint16(unpackedData[48:50])))
+	esdar.Crawford = int32(int16(binary.LittleEndian.Uint16(unpackedData[50:52])))
+	esdar.Met = int32(int16(binary.LittleEndian.Uint16(unpackedData[52:54])))
+	esdar.FlagDouble = int32(int16(binary.LittleEndian.Uint16(unpackedData[54:56])))
+	esdar.IsBeaver = int32(int16(binary.LittleEndian.Uint16(unpackedData[56:58])))
 	for i := 0; i < 7; i++ {
-		esdar.Eval[i] = float32(binary.LittleEndian.Uint32(unpackedData[66+i*4 : 70+i*4]))
+		esdar.Eval[i] = math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[60+i*4 : 64+i*4]))
 	}
-	esdar.EquB = float32(binary.LittleEndian.Uint32(unpackedData[94:98]))
-	esdar.EquDouble = float32(binary.LittleEndian.Uint32(unpackedData[98:102]))
-	esdar.EquDrop = float32(binary.LittleEndian.Uint32(unpackedData[102:106]))
-	esdar.LevelRequest = int32(binary.LittleEndian.Uint32(unpackedData[106:110]))
-	esdar.DoubleChoice3 = int32(binary.LittleEndian.Uint32(unpackedData[110:114]))
+	esdar.EquB = math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[88:92]))
+	esdar.EquDouble = math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[92:96]))
+	esdar.EquDrop = math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[96:100]))
+	esdar.LevelRequest = int32(int16(binary.LittleEndian.Uint16(unpackedData[100:102])))
+	esdar.DoubleChoice3 = int32(int16(binary.LittleEndian.Uint16(unpackedData[102:104])))
 	for i := 0; i < 7; i++ {
-		esdar.EvalDouble[i] = float32(binary.LittleEndian.Uint32(unpackedData[114+i*4 : 118+i*4]))
+		esdar.EvalDouble[i] = math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[104+i*4 : 108+i*4]))
 	}
 
 	return nil
 }
 
+func (esdar *EngineStructDoubleAction) MarshalBinary() ([]byte, error) {
+	packedData := make([]byte, XG_DOUBLEACTIONREC_LEN)
+
+	putInt8(packedData[0:26], esdar.Pos[:])
+	binary.LittleEndian.PutUint32(packedData[28:32], uint32(esdar.Level))
+	binary.LittleEndian.PutUint32(packedData[32:36], uint32(esdar.Score[0]))
+	binary.LittleEndian.PutUint32(packedData[36:40], uint32(esdar.Score[1]))
+	binary.LittleEndian.PutUint32(packedData[40:44], uint32(esdar.Cube))
+	binary.LittleEndian.PutUint32(packedData[44:48], uint32(esdar.CubePos))
+	binary.LittleEndian.PutUint16(packedData[48:50], uint16(esdar.Jacoby))
+	binary.LittleEndian.PutUint16(packedData[50:52], uint16(esdar.Crawford))
+	binary.LittleEndian.PutUint16(packedData[52:54], uint16(esdar.Met))
+	binary.LittleEndian.PutUint16(packedData[54:56], uint16(esdar.FlagDouble))
+	binary.LittleEndian.PutUint16(packedData[56:58], uint16(esdar.IsBeaver))
+	for i := 0; i < 7; i++ {
+		binary.LittleEndian.PutUint32(packedData[60+i*4:64+i*4], math.Float32bits(esdar.Eval[i]))
+	}
+	binary.LittleEndian.PutUint32(packedData[88:92], math.Float32bits(esdar.EquB))
+	binary.LittleEndian.PutUint32(packedData[92:96], math.Float32bits(esdar.EquDouble))
+	binary.LittleEndian.PutUint32(packedData[96:100], math.Float32bits(esdar.EquDrop))
+	binary.LittleEndian.PutUint16(packedData[100:102], uint16(esdar.LevelRequest))
+	binary.LittleEndian.PutUint16(packedData[102:104], uint16(esdar.DoubleChoice3))
+	for i := 0; i < 7; i++ {
+		binary.LittleEndian.PutUint32(packedData[104+i*4:108+i*4], math.Float32bits(esdar.EvalDouble[i]))
+	}
+
+	return packedData, nil
+}
+
 type HeaderMatchEntry struct {
 	Name                 string
 	EntryType            int32
@@ -300,10 +483,185 @@ type HeaderMatchEntry struct {
 }
 
 func (hme *HeaderMatchEntry) FromStream(stream io.Reader) error {
-	// Implement the method based on the Python version
+	// The match header is the first record of the game file. Fields up to
+	// the magic number are present in every version, the rest depend on
+	// the record version.
+	var unpackedData [2238]byte
+	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
+		return err
+	}
+
+	hme.Name = "MatchInfo"
+	hme.EntryType = int32(unpackedData[8])
+	hme.SPlayer1 = DelphiShortStrToStr(unpackedData[9:50])
+	hme.SPlayer2 = DelphiShortStrToStr(unpackedData[50:91])
+	hme.MatchLength = int32(binary.LittleEndian.Uint32(unpackedData[92:96]))
+	hme.Variation = int32(binary.LittleEndian.Uint32(unpackedData[96:100]))
+	hme.Crawford = unpackedData[100] != 0
+	hme.Jacoby = unpackedData[101] != 0
+	hme.Beaver = unpackedData[102] != 0
+	hme.AutoDouble = unpackedData[103] != 0
+	hme.Elo1 = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[104:112]))
+	hme.Elo2 = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[112:120]))
+	hme.Exp1 = int32(binary.LittleEndian.Uint32(unpackedData[120:124]))
+	hme.Exp2 = int32(binary.LittleEndian.Uint32(unpackedData[124:128]))
+	hme.Date = DelphiDateTimeConv(math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[128:136]))).Format(delphiDateLayout)
+	hme.SEvent = DelphiShortStrToStr(unpackedData[136:265])
+	hme.GameId = int32(binary.LittleEndian.Uint32(unpackedData[268:272]))
+	hme.CompLevel1 = int32(binary.LittleEndian.Uint32(unpackedData[272:276]))
+	hme.CompLevel2 = int32(binary.LittleEndian.Uint32(unpackedData[276:280]))
+	hme.CountForElo = unpackedData[280] != 0
+	hme.AddtoProfile1 = unpackedData[281] != 0
+	hme.AddtoProfile2 = unpackedData[282] != 0
+	hme.SLocation = DelphiShortStrToStr(unpackedData[283:412])
+	hme.GameMode = int32(binary.LittleEndian.Uint32(unpackedData[412:416]))
+	hme.Imported = unpackedData[416] != 0
+	hme.SRound = DelphiShortStrToStr(unpackedData[417:546])
+	hme.Invert = int32(binary.LittleEndian.Uint32(unpackedData[548:552]))
+	hme.Version = int32(binary.LittleEndian.Uint32(unpackedData[552:556]))
+	hme.Magic = int32(binary.LittleEndian.Uint32(unpackedData[556:560]))
+	if hme.Magic != matchHeaderMagic {
+		return newError(ErrBadMagic, 556, nil)
+	}
+
+	// Defaults for fields that older versions do not store.
+	hme.CommentHeaderMatch = -1
+	hme.CommentFooterMatch = -1
+	hme.SiteId = -1
+
+	if hme.Version >= 1 {
+		hme.MoneyInitG = int32(binary.LittleEndian.Uint32(unpackedData[560:564]))
+		hme.MoneyInitScore[0] = int32(binary.LittleEndian.Uint32(unpackedData[564:568]))
+		hme.MoneyInitScore[1] = int32(binary.LittleEndian.Uint32(unpackedData[568:572]))
+		hme.Entered = unpackedData[572] != 0
+		hme.Counted = unpackedData[573] != 0
+		hme.UnratedImp = unpackedData[574] != 0
+		hme.CommentHeaderMatch = int32(binary.LittleEndian.Uint32(unpackedData[576:580]))
+		hme.CommentFooterMatch = int32(binary.LittleEndian.Uint32(unpackedData[580:584]))
+		hme.IsMoneyMatch = unpackedData[584] != 0
+		hme.WinMoney = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[592:600]))
+		hme.LoseMoney = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[600:608]))
+		hme.Currency = int32(binary.LittleEndian.Uint32(unpackedData[608:612]))
+		hme.FeeMoney = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[616:624]))
+		hme.TableStake = int32(binary.LittleEndian.Uint32(unpackedData[624:628]))
+		hme.SiteId = int32(binary.LittleEndian.Uint32(unpackedData[628:632]))
+	}
+	if hme.Version >= 8 {
+		hme.CubeLimit = int32(binary.LittleEndian.Uint32(unpackedData[632:636]))
+		hme.AutoDoubleMax = int32(binary.LittleEndian.Uint32(unpackedData[636:640]))
+	}
+	if hme.Version >= 24 {
+		hme.Transcribed = unpackedData[640] != 0
+		hme.Event = UTF16IntArrayToStr(unpackUint16Array(unpackedData[642:900]))
+		hme.Player1 = UTF16IntArrayToStr(unpackUint16Array(unpackedData[900:1158]))
+		hme.Player2 = UTF16IntArrayToStr(unpackUint16Array(unpackedData[1158:1416]))
+		hme.Location = UTF16IntArrayToStr(unpackUint16Array(unpackedData[1416:1674]))
+		hme.Round = UTF16IntArrayToStr(unpackUint16Array(unpackedData[1674:1932]))
+	}
+	if hme.Version >= 25 {
+		if err := hme.TimeSetting.FromStream(bytes.NewReader(unpackedData[1932:1964])); err != nil {
+			return err
+		}
+	}
+	if hme.Version >= 26 {
+		hme.TotTimeDelayMove = int32(binary.LittleEndian.Uint32(unpackedData[1964:1968]))
+		hme.TotTimeDelayCube = int32(binary.LittleEndian.Uint32(unpackedData[1968:1972]))
+		hme.TotTimeDelayMoveDone = int32(binary.LittleEndian.Uint32(unpackedData[1972:1976]))
+		hme.TotTimeDelayCubeDone = int32(binary.LittleEndian.Uint32(unpackedData[1976:1980]))
+	}
+	if hme.Version >= 27 {
+		hme.Transcriber = UTF16IntArrayToStr(unpackUint16Array(unpackedData[1980:2238]))
+	}
+
 	return nil
 }
 
+func (hme *HeaderMatchEntry) MarshalBinary() ([]byte, error) {
+	// Fields the record version does not store are left zero.
+	date, err := parseDelphiDate(hme.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	packedData := make([]byte, 2238)
+	packedData[8] = ENTRYTYPE_HEADERMATCH
+	copy(packedData[9:50], StrToDelphiShortStr(hme.SPlayer1, 41))
+	copy(packedData[50:91], StrToDelphiShortStr(hme.SPlayer2, 41))
+	binary.LittleEndian.PutUint32(packedData[92:96], uint32(hme.MatchLength))
+	binary.LittleEndian.PutUint32(packedData[96:100], uint32(hme.Variation))
+	packedData[100] = boolToByte(hme.Crawford)
+	packedData[101] = boolToByte(hme.Jacoby)
+	packedData[102] = boolToByte(hme.Beaver)
+	packedData[103] = boolToByte(hme.AutoDouble)
+	binary.LittleEndian.PutUint64(packedData[104:112], math.Float64bits(hme.Elo1))
+	binary.LittleEndian.PutUint64(packedData[112:120], math.Float64bits(hme.Elo2))
+	binary.LittleEndian.PutUint32(packedData[120:124], uint32(hme.Exp1))
+	binary.LittleEndian.PutUint32(packedData[124:128], uint32(hme.Exp2))
+	binary.LittleEndian.PutUint64(packedData[128:136], math.Float64bits(date))
+	copy(packedData[136:265], StrToDelphiShortStr(hme.SEvent, 129))
+	binary.LittleEndian.PutUint32(packedData[268:272], uint32(hme.GameId))
+	binary.LittleEndian.PutUint32(packedData[272:276], uint32(hme.CompLevel1))
+	binary.LittleEndian.PutUint32(packedData[276:280], uint32(hme.CompLevel2))
+	packedData[280] = boolToByte(hme.CountForElo)
+	packedData[281] = boolToByte(hme.AddtoProfile1)
+	packedData[282] = boolToByte(hme.AddtoProfile2)
+	copy(packedData[283:412], StrToDelphiShortStr(hme.SLocation, 129))
+	binary.LittleEndian.PutUint32(packedData[412:416], uint32(hme.GameMode))
+	packedData[416] = boolToByte(hme.Imported)
+	copy(packedData[417:546], StrToDelphiShortStr(hme.SRound, 129))
+	binary.LittleEndian.PutUint32(packedData[548:552], uint32(hme.Invert))
+	binary.LittleEndian.PutUint32(packedData[552:556], uint32(hme.Version))
+	binary.LittleEndian.PutUint32(packedData[556:560], matchHeaderMagic)
+
+	if hme.Version >= 1 {
+		binary.LittleEndian.PutUint32(packedData[560:564], uint32(hme.MoneyInitG))
+		binary.LittleEndian.PutUint32(packedData[564:568], uint32(hme.MoneyInitScore[0]))
+		binary.LittleEndian.PutUint32(packedData[568:572], uint32(hme.MoneyInitScore[1]))
+		packedData[572] = boolToByte(hme.Entered)
+		packedData[573] = boolToByte(hme.Counted)
+		packedData[574] = boolToByte(hme.UnratedImp)
+		binary.LittleEndian.PutUint32(packedData[576:580], uint32(hme.CommentHeaderMatch))
+		binary.LittleEndian.PutUint32(packedData[580:584], uint32(hme.CommentFooterMatch))
+		packedData[584] = boolToByte(hme.IsMoneyMatch)
+		binary.LittleEndian.PutUint64(packedData[592:600], math.Float64bits(hme.WinMoney))
+		binary.LittleEndian.PutUint64(packedData[600:608], math.Float64bits(hme.LoseMoney))
+		binary.LittleEndian.PutUint32(packedData[608:612], uint32(hme.Currency))
+		binary.LittleEndian.PutUint64(packedData[616:624], math.Float64bits(hme.FeeMoney))
+		binary.LittleEndian.PutUint32(packedData[624:628], uint32(hme.TableStake))
+		binary.LittleEndian.PutUint32(packedData[628:632], uint32(hme.SiteId))
+	}
+	if hme.Version >= 8 {
+		binary.LittleEndian.PutUint32(packedData[632:636], uint32(hme.CubeLimit))
+		binary.LittleEndian.PutUint32(packedData[636:640], uint32(hme.AutoDoubleMax))
+	}
+	if hme.Version >= 24 {
+		packedData[640] = boolToByte(hme.Transcribed)
+		packUint16Array(packedData[642:900], StrToUTF16IntArray(hme.Event, 129))
+		packUint16Array(packedData[900:1158], StrToUTF16IntArray(hme.Player1, 129))
+		packUint16Array(packedData[1158:1416], StrToUTF16IntArray(hme.Player2, 129))
+		packUint16Array(packedData[1416:1674], StrToUTF16IntArray(hme.Location, 129))
+		packUint16Array(packedData[1674:1932], StrToUTF16IntArray(hme.Round, 129))
+	}
+	if hme.Version >= 25 {
+		timeSetting, err := hme.TimeSetting.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		copy(packedData[1932:1964], timeSetting)
+	}
+	if hme.Version >= 26 {
+		binary.LittleEndian.PutUint32(packedData[1964:1968], uint32(hme.TotTimeDelayMove))
+		binary.LittleEndian.PutUint32(packedData[1968:1972], uint32(hme.TotTimeDelayCube))
+		binary.LittleEndian.PutUint32(packedData[1972:1976], uint32(hme.TotTimeDelayMoveDone))
+		binary.LittleEndian.PutUint32(packedData[1976:1980], uint32(hme.TotTimeDelayCubeDone))
+	}
+	if hme.Version >= 27 {
+		packUint16Array(packedData[1980:2238], StrToUTF16IntArray(hme.Transcriber, 129))
+	}
+
+	return packedData, nil
+}
+
 type FooterGameEntry struct {
 	Name           string
 	EntryType      int32
@@ -320,10 +678,49 @@ type FooterGameEntry struct {
 }
 
 func (fge *FooterGameEntry) FromStream(stream io.Reader) error {
-	// Implement the method based on the Python version
+	var unpackedData [88]byte
+	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
+		return err
+	}
+
+	fge.Name = "GameFooter"
+	fge.EntryType = int32(unpackedData[8])
+	fge.Score1g = int32(binary.LittleEndian.Uint32(unpackedData[12:16]))
+	fge.Score2g = int32(binary.LittleEndian.Uint32(unpackedData[16:20]))
+	fge.CrawfordApplyg = unpackedData[20] != 0
+	fge.Winner = int32(binary.LittleEndian.Uint32(unpackedData[24:28]))
+	fge.PointsWon = int32(binary.LittleEndian.Uint32(unpackedData[28:32]))
+	fge.Termination = int32(binary.LittleEndian.Uint32(unpackedData[32:36]))
+	fge.ErrResign = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[40:48]))
+	fge.ErrTakeResign = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[48:56]))
+	for i := 0; i < 7; i++ {
+		fge.Eval[i] = math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[56+i*4 : 60+i*4]))
+	}
+	fge.EvalLevel = int32(binary.LittleEndian.Uint32(unpackedData[84:88]))
+
 	return nil
 }
 
+func (fge *FooterGameEntry) MarshalBinary() ([]byte, error) {
+	packedData := make([]byte, 88)
+
+	packedData[8] = ENTRYTYPE_FOOTERGAME
+	binary.LittleEndian.PutUint32(packedData[12:16], uint32(fge.Score1g))
+	binary.LittleEndian.PutUint32(packedData[16:20], uint32(fge.Score2g))
+	packedData[20] = boolToByte(fge.CrawfordApplyg)
+	binary.LittleEndian.PutUint32(packedData[24:28], uint32(fge.Winner))
+	binary.LittleEndian.PutUint32(packedData[28:32], uint32(fge.PointsWon))
+	binary.LittleEndian.PutUint32(packedData[32:36], uint32(fge.Termination))
+	binary.LittleEndian.PutUint64(packedData[40:48], math.Float64bits(fge.ErrResign))
+	binary.LittleEndian.PutUint64(packedData[48:56], math.Float64bits(fge.ErrTakeResign))
+	for i := 0; i < 7; i++ {
+		binary.LittleEndian.PutUint32(packedData[56+i*4:60+i*4], math.Float32bits(fge.Eval[i]))
+	}
+	binary.LittleEndian.PutUint32(packedData[84:88], uint32(fge.EvalLevel))
+
+	return packedData, nil
+}
+
 type MissingEntry struct {
 	Name           string
 	EntryType      int32
@@ -333,10 +730,31 @@ type MissingEntry struct {
 }
 
 func (me *MissingEntry) FromStream(stream io.Reader) error {
-	// Implement the method based on the Python version
+	var unpackedData [32]byte
+	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
+		return err
+	}
+
+	me.Name = "Missing"
+	me.EntryType = int32(unpackedData[8])
+	me.MissingErrLuck = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[16:24]))
+	me.MissingWinner = int32(binary.LittleEndian.Uint32(unpackedData[24:28]))
+	me.MissingPoints = int32(binary.LittleEndian.Uint32(unpackedData[28:32]))
+
 	return nil
 }
 
+func (me *MissingEntry) MarshalBinary() ([]byte, error) {
+	packedData := make([]byte, 32)
+
+	packedData[8] = ENTRYTYPE_MISSING
+	binary.LittleEndian.PutUint64(packedData[16:24], math.Float64bits(me.MissingErrLuck))
+	binary.LittleEndian.PutUint32(packedData[24:28], uint32(me.MissingWinner))
+	binary.LittleEndian.PutUint32(packedData[28:32], uint32(me.MissingPoints))
+
+	return packedData, nil
+}
+
 type FooterMatchEntry struct {
 	Name      string
 	EntryType int32
@@ -351,10 +769,45 @@ type FooterMatchEntry struct {
 }
 
 func (fme *FooterMatchEntry) FromStream(stream io.Reader) error {
-	// Implement the method based on the Python version
+	var unpackedData [56]byte
+	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
+		return err
+	}
+
+	fme.Name = "MatchFooter"
+	fme.EntryType = int32(unpackedData[8])
+	fme.Score1m = int32(binary.LittleEndian.Uint32(unpackedData[12:16]))
+	fme.Score2m = int32(binary.LittleEndian.Uint32(unpackedData[16:20]))
+	fme.WinnerM = int32(binary.LittleEndian.Uint32(unpackedData[20:24]))
+	fme.Elo1m = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[24:32]))
+	fme.Elo2m = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[32:40]))
+	fme.Exp1m = int32(binary.LittleEndian.Uint32(unpackedData[40:44]))
+	fme.Exp2m = int32(binary.LittleEndian.Uint32(unpackedData[44:48]))
+	fme.Datem = DelphiDateTimeConv(math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[48:56]))).Format(delphiDateLayout)
+
 	return nil
 }
 
+func (fme *FooterMatchEntry) MarshalBinary() ([]byte, error) {
+	date, err := parseDelphiDate(fme.Datem)
+	if err != nil {
+		return nil, err
+	}
+
+	packedData := make([]byte, 56)
+	packedData[8] = ENTRYTYPE_FOOTERMATCH
+	binary.LittleEndian.PutUint32(packedData[12:16], uint32(fme.Score1m))
+	binary.LittleEndian.PutUint32(packedData[16:20], uint32(fme.Score2m))
+	binary.LittleEndian.PutUint32(packedData[20:24], uint32(fme.WinnerM))
+	binary.LittleEndian.PutUint64(packedData[24:32], math.Float64bits(fme.Elo1m))
+	binary.LittleEndian.PutUint64(packedData[32:40], math.Float64bits(fme.Elo2m))
+	binary.LittleEndian.PutUint32(packedData[40:44], uint32(fme.Exp1m))
+	binary.LittleEndian.PutUint32(packedData[44:48], uint32(fme.Exp2m))
+	binary.LittleEndian.PutUint64(packedData[48:56], math.Float64bits(date))
+
+	return packedData, nil
+}
+
 type HeaderGameEntry struct {
 	Name                string
 	EntryType           int32
@@ -367,13 +820,64 @@ type HeaderGameEntry struct {
 	CommentHeaderGame   int32
 	CommentFooterGame   int32
 	NumberOfAutoDoubles int32
+
+	version int32
+}
+
+// NewHeaderGameEntry returns a HeaderGameEntry that decodes records written
+// with the given game file version.
+func NewHeaderGameEntry(version int32) *HeaderGameEntry {
+	return &HeaderGameEntry{Name: "GameHeader", version: version}
 }
 
 func (hge *HeaderGameEntry) FromStream(stream io.Reader) error {
-	// Implement the method based on the Python version
+	var unpackedData [68]byte
+	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
+		return err
+	}
+
+	hge.Name = "GameHeader"
+	hge.EntryType = int32(unpackedData[8])
+	hge.Score1 = int32(binary.LittleEndian.Uint32(unpackedData[12:16]))
+	hge.Score2 = int32(binary.LittleEndian.Uint32(unpackedData[16:20]))
+	hge.CrawfordApply = unpackedData[20] != 0
+	copyInt8(hge.PosInit[:], unpackedData[21:47])
+	hge.GameNumber = int32(binary.LittleEndian.Uint32(unpackedData[48:52]))
+	hge.InProgress = unpackedData[52] != 0
+	hge.CommentHeaderGame = int32(binary.LittleEndian.Uint32(unpackedData[56:60]))
+	hge.CommentFooterGame = int32(binary.LittleEndian.Uint32(unpackedData[60:64]))
+
+	if hge.version >= 26 {
+		hge.NumberOfAutoDoubles = int32(binary.LittleEndian.Uint32(unpackedData[64:68]))
+	}
+
 	return nil
 }
 
+func (hge *HeaderGameEntry) MarshalBinary() ([]byte, error) {
+	return hge.marshal(hge.version)
+}
+
+// marshal encodes the record for a game file of the given version.
+func (hge *HeaderGameEntry) marshal(version int32) ([]byte, error) {
+	packedData := make([]byte, 68)
+	packedData[8] = ENTRYTYPE_HEADERGAME
+	binary.LittleEndian.PutUint32(packedData[12:16], uint32(hge.Score1))
+	binary.LittleEndian.PutUint32(packedData[16:20], uint32(hge.Score2))
+	packedData[20] = boolToByte(hge.CrawfordApply)
+	putInt8(packedData[21:47], hge.PosInit[:])
+	binary.LittleEndian.PutUint32(packedData[48:52], uint32(hge.GameNumber))
+	packedData[52] = boolToByte(hge.InProgress)
+	binary.LittleEndian.PutUint32(packedData[56:60], uint32(hge.CommentHeaderGame))
+	binary.LittleEndian.PutUint32(packedData[60:64], uint32(hge.CommentFooterGame))
+
+	if version >= 26 {
+		binary.LittleEndian.PutUint32(packedData[64:68], uint32(hge.NumberOfAutoDoubles))
+	}
+
+	return packedData, nil
+}
+
 type CubeEntry struct {
 	Name                   string
 	EntryType              int32
@@ -407,13 +911,134 @@ type CubeEntry struct {
 	NumberOfAutoDoubleCube int32
 	TimeBot                int32
 	TimeTop                int32
+
+	version int32
+}
+
+// NewCubeEntry returns a CubeEntry that decodes records written with the
+// given game file version.
+func NewCubeEntry(version int32) *CubeEntry {
+	return &CubeEntry{Name: "Cube", version: version}
 }
 
 func (ce *CubeEntry) FromStream(stream io.Reader) error {
-	// Implement the method based on the Python version
+	var unpackedData [64]byte
+	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
+		return err
+	}
+
+	ce.Name = "Cube"
+	ce.EntryType = int32(unpackedData[8])
+	ce.ActiveP = int32(binary.LittleEndian.Uint32(unpackedData[12:16]))
+	ce.Double = int32(binary.LittleEndian.Uint32(unpackedData[16:20]))
+	ce.Take = int32(binary.LittleEndian.Uint32(unpackedData[20:24]))
+	ce.BeaverR = int32(binary.LittleEndian.Uint32(unpackedData[24:28]))
+	ce.RaccoonR = int32(binary.LittleEndian.Uint32(unpackedData[28:32]))
+	ce.CubeB = int32(binary.LittleEndian.Uint32(unpackedData[32:36]))
+	copyInt8(ce.Position[:], unpackedData[36:62])
+
+	if err := ce.Doubled.FromStream(stream); err != nil {
+		return err
+	}
+
+	var trailerData [116]byte
+	if _, err := io.ReadFull(stream, trailerData[:]); err != nil {
+		return err
+	}
+
+	ce.ErrCube = math.Float64frombits(binary.LittleEndian.Uint64(trailerData[4:12]))
+	ce.DiceRolled = DelphiShortStrToStr(trailerData[12:15])
+	ce.ErrTake = math.Float64frombits(binary.LittleEndian.Uint64(trailerData[20:28]))
+	ce.RolloutIndexD = int32(binary.LittleEndian.Uint32(trailerData[28:32]))
+	ce.CompChoiceD = int32(binary.LittleEndian.Uint32(trailerData[32:36]))
+	ce.AnalyzeC = int32(binary.LittleEndian.Uint32(trailerData[36:40]))
+	ce.ErrBeaver = math.Float64frombits(binary.LittleEndian.Uint64(trailerData[44:52]))
+	ce.ErrRaccoon = math.Float64frombits(binary.LittleEndian.Uint64(trailerData[52:60]))
+	ce.AnalyzeCR = int32(binary.LittleEndian.Uint32(trailerData[60:64]))
+	ce.IsValid = int32(binary.LittleEndian.Uint32(trailerData[64:68]))
+	ce.TutorCube = int32(binary.LittleEndian.Uint32(trailerData[68:72]))
+	ce.TutorTake = int32(binary.LittleEndian.Uint32(trailerData[72:76]))
+	ce.ErrTutorCube = math.Float64frombits(binary.LittleEndian.Uint64(trailerData[76:84]))
+	ce.ErrTutorTake = math.Float64frombits(binary.LittleEndian.Uint64(trailerData[84:92]))
+	ce.FlaggedDouble = trailerData[92] != 0
+	ce.CommentCube = int32(binary.LittleEndian.Uint32(trailerData[96:100]))
+
+	if ce.version >= 24 {
+		ce.EditedCube = trailerData[100] != 0
+	}
+	if ce.version >= 26 {
+		ce.TimeDelayCube = trailerData[101] != 0
+		ce.TimeDelayCubeDone = trailerData[102] != 0
+	}
+	if ce.version >= 27 {
+		ce.NumberOfAutoDoubleCube = int32(binary.LittleEndian.Uint32(trailerData[104:108]))
+	}
+	if ce.version >= 28 {
+		ce.TimeBot = int32(binary.LittleEndian.Uint32(trailerData[108:112]))
+		ce.TimeTop = int32(binary.LittleEndian.Uint32(trailerData[112:116]))
+	}
+
 	return nil
 }
 
+func (ce *CubeEntry) MarshalBinary() ([]byte, error) {
+	return ce.marshal(ce.version)
+}
+
+// marshal encodes the record for a game file of the given version.
+func (ce *CubeEntry) marshal(version int32) ([]byte, error) {
+	packedData := make([]byte, 64)
+	packedData[8] = ENTRYTYPE_CUBE
+	binary.LittleEndian.PutUint32(packedData[12:16], uint32(ce.ActiveP))
+	binary.LittleEndian.PutUint32(packedData[16:20], uint32(ce.Double))
+	binary.LittleEndian.PutUint32(packedData[20:24], uint32(ce.Take))
+	binary.LittleEndian.PutUint32(packedData[24:28], uint32(ce.BeaverR))
+	binary.LittleEndian.PutUint32(packedData[28:32], uint32(ce.RaccoonR))
+	binary.LittleEndian.PutUint32(packedData[32:36], uint32(ce.CubeB))
+	putInt8(packedData[36:62], ce.Position[:])
+
+	doubled, err := ce.Doubled.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	packedData = append(packedData, doubled...)
+
+	trailerData := make([]byte, 116)
+	binary.LittleEndian.PutUint64(trailerData[4:12], math.Float64bits(ce.ErrCube))
+	copy(trailerData[12:15], StrToDelphiShortStr(ce.DiceRolled, 3))
+	binary.LittleEndian.PutUint64(trailerData[20:28], math.Float64bits(ce.ErrTake))
+	binary.LittleEndian.PutUint32(trailerData[28:32], uint32(ce.RolloutIndexD))
+	binary.LittleEndian.PutUint32(trailerData[32:36], uint32(ce.CompChoiceD))
+	binary.LittleEndian.PutUint32(trailerData[36:40], uint32(ce.AnalyzeC))
+	binary.LittleEndian.PutUint64(trailerData[44:52], math.Float64bits(ce.ErrBeaver))
+	binary.LittleEndian.PutUint64(trailerData[52:60], math.Float64bits(ce.ErrRaccoon))
+	binary.LittleEndian.PutUint32(trailerData[60:64], uint32(ce.AnalyzeCR))
+	binary.LittleEndian.PutUint32(trailerData[64:68], uint32(ce.IsValid))
+	binary.LittleEndian.PutUint32(trailerData[68:72], uint32(ce.TutorCube))
+	binary.LittleEndian.PutUint32(trailerData[72:76], uint32(ce.TutorTake))
+	binary.LittleEndian.PutUint64(trailerData[76:84], math.Float64bits(ce.ErrTutorCube))
+	binary.LittleEndian.PutUint64(trailerData[84:92], math.Float64bits(ce.ErrTutorTake))
+	trailerData[92] = boolToByte(ce.FlaggedDouble)
+	binary.LittleEndian.PutUint32(trailerData[96:100], uint32(ce.CommentCube))
+
+	if version >= 24 {
+		trailerData[100] = boolToByte(ce.EditedCube)
+	}
+	if version >= 26 {
+		trailerData[101] = boolToByte(ce.TimeDelayCube)
+		trailerData[102] = boolToByte(ce.TimeDelayCubeDone)
+	}
+	if version >= 27 {
+		binary.LittleEndian.PutUint32(trailerData[104:108], uint32(ce.NumberOfAutoDoubleCube))
+	}
+	if version >= 28 {
+		binary.LittleEndian.PutUint32(trailerData[108:112], uint32(ce.TimeBot))
+		binary.LittleEndian.PutUint32(trailerData[112:116], uint32(ce.TimeTop))
+	}
+
+	return append(packedData, trailerData...), nil
+}
+
 type MoveEntry struct {
 	Name                   string
 	EntryType              int32
@@ -444,22 +1069,200 @@ type MoveEntry struct {
 	TimeDelayMove          int32
 	TimeDelayMoveDone      int32
 	NumberOfAutoDoubleMove int32
+
+	version int32
+}
+
+// NewMoveEntry returns a MoveEntry that decodes records written with the
+// given game file version.
+func NewMoveEntry(version int32) *MoveEntry {
+	return &MoveEntry{Name: "Move", version: version}
 }
 
 func (me *MoveEntry) FromStream(stream io.Reader) error {
-	// Implement the method based on the Python version
+	var unpackedData [120]byte
+	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
+		return err
+	}
+
+	me.Name = "Move"
+	me.EntryType = int32(unpackedData[8])
+	copyInt8(me.PositionI[:], unpackedData[9:35])
+	copyInt8(me.PositionEnd[:], unpackedData[35:61])
+	me.ActiveP = int32(binary.LittleEndian.Uint32(unpackedData[64:68]))
+	for i := 0; i < 8; i++ {
+		me.Moves[i] = int32(binary.LittleEndian.Uint32(unpackedData[68+i*4 : 72+i*4]))
+	}
+	me.Dice[0] = int32(binary.LittleEndian.Uint32(unpackedData[100:104]))
+	me.Dice[1] = int32(binary.LittleEndian.Uint32(unpackedData[104:108]))
+	me.CubeA = int32(binary.LittleEndian.Uint32(unpackedData[108:112]))
+	me.ErrorM = int32(binary.LittleEndian.Uint32(unpackedData[112:116]))
+	me.NMoveEval = int32(binary.LittleEndian.Uint32(unpackedData[116:120]))
+
+	if err := me.DataMoves.FromStream(stream); err != nil {
+		return err
+	}
+
+	var trailerData [248]byte
+	if _, err := io.ReadFull(stream, trailerData[:]); err != nil {
+		return err
+	}
+
+	me.Played = trailerData[0] != 0
+	me.ErrMove = math.Float64frombits(binary.LittleEndian.Uint64(trailerData[8:16]))
+	me.ErrLuck = math.Float64frombits(binary.LittleEndian.Uint64(trailerData[16:24]))
+	me.CompChoice = int32(binary.LittleEndian.Uint32(trailerData[24:28]))
+	me.InitEq = math.Float64frombits(binary.LittleEndian.Uint64(trailerData[32:40]))
+	for i := 0; i < 32; i++ {
+		me.RolloutIndexM[i] = int32(binary.LittleEndian.Uint32(trailerData[40+i*4 : 44+i*4]))
+	}
+	me.AnalyzeM = int32(binary.LittleEndian.Uint32(trailerData[168:172]))
+	me.AnalyzeL = int32(binary.LittleEndian.Uint32(trailerData[172:176]))
+	me.InvalidM = int32(binary.LittleEndian.Uint32(trailerData[176:180]))
+	copyInt8(me.PositionTutor[:], trailerData[180:206])
+	me.Tutor = int32(binary.LittleEndian.Uint32(trailerData[208:212]))
+	me.ErrTutorMove = math.Float64frombits(binary.LittleEndian.Uint64(trailerData[216:224]))
+	me.Flagged = trailerData[224] != 0
+	me.CommentMove = int32(binary.LittleEndian.Uint32(trailerData[228:232]))
+
+	if me.version >= 24 {
+		me.EditedMove = trailerData[232] != 0
+	}
+	if me.version >= 26 {
+		me.TimeDelayMove = int32(binary.LittleEndian.Uint32(trailerData[236:240]))
+		me.TimeDelayMoveDone = int32(binary.LittleEndian.Uint32(trailerData[240:244]))
+	}
+	if me.version >= 27 {
+		me.NumberOfAutoDoubleMove = int32(binary.LittleEndian.Uint32(trailerData[244:248]))
+	}
+
 	return nil
 }
 
+func (me *MoveEntry) MarshalBinary() ([]byte, error) {
+	return me.marshal(me.version)
+}
+
+// marshal encodes the record for a game file of the given version.
+func (me *MoveEntry) marshal(version int32) ([]byte, error) {
+	packedData := make([]byte, 120)
+	packedData[8] = ENTRYTYPE_MOVE
+	putInt8(packedData[9:35], me.PositionI[:])
+	putInt8(packedData[35:61], me.PositionEnd[:])
+	binary.LittleEndian.PutUint32(packedData[64:68], uint32(me.ActiveP))
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint32(packedData[68+i*4:72+i*4], uint32(me.Moves[i]))
+	}
+	binary.LittleEndian.PutUint32(packedData[100:104], uint32(me.Dice[0]))
+	binary.LittleEndian.PutUint32(packedData[104:108], uint32(me.Dice[1]))
+	binary.LittleEndian.PutUint32(packedData[108:112], uint32(me.CubeA))
+	binary.LittleEndian.PutUint32(packedData[112:116], uint32(me.ErrorM))
+	binary.LittleEndian.PutUint32(packedData[116:120], uint32(me.NMoveEval))
+
+	dataMoves, err := me.DataMoves.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	packedData = append(packedData, dataMoves...)
+
+	trailerData := make([]byte, 248)
+	trailerData[0] = boolToByte(me.Played)
+	binary.LittleEndian.PutUint64(trailerData[8:16], math.Float64bits(me.ErrMove))
+	binary.LittleEndian.PutUint64(trailerData[16:24], math.Float64bits(me.ErrLuck))
+	binary.LittleEndian.PutUint32(trailerData[24:28], uint32(me.CompChoice))
+	binary.LittleEndian.PutUint64(trailerData[32:40], math.Float64bits(me.InitEq))
+	for i := 0; i < 32; i++ {
+		binary.LittleEndian.PutUint32(trailerData[40+i*4:44+i*4], uint32(me.RolloutIndexM[i]))
+	}
+	binary.LittleEndian.PutUint32(trailerData[168:172], uint32(me.AnalyzeM))
+	binary.LittleEndian.PutUint32(trailerData[172:176], uint32(me.AnalyzeL))
+	binary.LittleEndian.PutUint32(trailerData[176:180], uint32(me.InvalidM))
+	putInt8(trailerData[180:206], me.PositionTutor[:])
+	binary.LittleEndian.PutUint32(trailerData[208:212], uint32(me.Tutor))
+	binary.LittleEndian.PutUint64(trailerData[216:224], math.Float64bits(me.ErrTutorMove))
+	trailerData[224] = boolToByte(me.Flagged)
+	binary.LittleEndian.PutUint32(trailerData[228:232], uint32(me.CommentMove))
+
+	if version >= 24 {
+		trailerData[232] = boolToByte(me.EditedMove)
+	}
+	if version >= 26 {
+		binary.LittleEndian.PutUint32(trailerData[236:240], uint32(me.TimeDelayMove))
+		binary.LittleEndian.PutUint32(trailerData[240:244], uint32(me.TimeDelayMoveDone))
+	}
+	if version >= 27 {
+		binary.LittleEndian.PutUint32(trailerData[244:248], uint32(me.NumberOfAutoDoubleMove))
+	}
+
+	return append(packedData, trailerData...), nil
+}
+
 type UnimplementedEntry struct {
 	Name string
 }
 
 func (ue *UnimplementedEntry) FromStream(stream io.Reader) error {
-	// Implement the method based on the Python version
+	ue.Name = "Unimplemented"
 	return nil
 }
 
+func (ue *UnimplementedEntry) MarshalBinary() ([]byte, error) {
+	return nil, fmt.Errorf("cannot encode an unimplemented entry: %w", ErrUnknownEntryType)
+}
+
+// GameHdrRecord is the content of the temp.xgi segment. It repeats the
+// match header record of the game file so the players and settings of a
+// match can be read without walking its games.
+type GameHdrRecord struct {
+	Magic   string
+	Version int32
+	Match   HeaderMatchEntry
+}
+
+func (ghr *GameHdrRecord) FromStream(stream io.Reader) error {
+	// Older files only store the part of the record up to the magic
+	// number, so pad short segments to a full record before decoding.
+	var unpackedData [XG_GAMEFILEREC_LEN]byte
+	n, err := io.ReadFull(stream, unpackedData[:])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	if n < XG_GAMEHDR_LEN+4 {
+		return newError(ErrTruncated, int64(n), err)
+	}
+
+	ghr.Magic = string(unpackedData[XG_GAMEHDR_LEN : XG_GAMEHDR_LEN+4])
+	if ghr.Magic != "DMLI" {
+		return newError(ErrBadMagic, XG_GAMEHDR_LEN, nil)
+	}
+	// Version 0 records are decoded like HeaderMatchEntry does, with
+	// defaults for the fields they lack.
+	ghr.Version = int32(binary.LittleEndian.Uint32(unpackedData[XG_GAMEHDR_LEN-4 : XG_GAMEHDR_LEN]))
+	return ghr.Match.FromStream(bytes.NewReader(unpackedData[:]))
+}
+
+// MarshalBinary encodes the match header as a full game file record, the
+// form XG writes to temp.xgi.
+func (ghr *GameHdrRecord) MarshalBinary() ([]byte, error) {
+	gfr := GameFileRecord{Record: &ghr.Match, Version: ghr.Match.Version}
+	return gfr.MarshalBinary()
+}
+
+// Entry types stored in the first byte after the record prelude of every
+// game file record.
+const (
+	ENTRYTYPE_HEADERMATCH = iota
+	ENTRYTYPE_HEADERGAME
+	ENTRYTYPE_CUBE
+	ENTRYTYPE_MOVE
+	ENTRYTYPE_FOOTERGAME
+	ENTRYTYPE_FOOTERMATCH
+	ENTRYTYPE_MISSING
+)
+
+// XG_GAMEFILEREC_LEN is the fixed size of every record in the game file.
+const XG_GAMEFILEREC_LEN = 2560
+
 type GameFileRecord struct {
 	Name      string
 	EntryType int32
@@ -467,11 +1270,119 @@ type GameFileRecord struct {
 	Version   int32
 }
 
+// FromStream reads one fixed-size record and decodes it into the concrete
+// entry type given by its EntryType. Version must hold the version of the
+// match header already read; it is updated when a match header is decoded.
+// io.EOF is returned when the stream holds no more records. A record of an
+// unknown entry type, such as one added by a later XG release, is decoded
+// as an UnimplementedEntry so readers can skip it.
 func (gfr *GameFileRecord) FromStream(stream io.Reader) error {
-	// Implement the method based on the Python version
+	var unpackedData [XG_GAMEFILEREC_LEN]byte
+	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
+		return err
+	}
+
+	gfr.EntryType = int32(unpackedData[8])
+	recStream := bytes.NewReader(unpackedData[:])
+
+	switch gfr.EntryType {
+	case ENTRYTYPE_HEADERMATCH:
+		rec := &HeaderMatchEntry{}
+		if err := rec.FromStream(recStream); err != nil {
+			return err
+		}
+		gfr.Version = rec.Version
+		gfr.Name = rec.Name
+		gfr.Record = rec
+	case ENTRYTYPE_HEADERGAME:
+		rec := NewHeaderGameEntry(gfr.Version)
+		if err := rec.FromStream(recStream); err != nil {
+			return err
+		}
+		gfr.Name = rec.Name
+		gfr.Record = rec
+	case ENTRYTYPE_CUBE:
+		rec := NewCubeEntry(gfr.Version)
+		if err := rec.FromStream(recStream); err != nil {
+			return err
+		}
+		gfr.Name = rec.Name
+		gfr.Record = rec
+	case ENTRYTYPE_MOVE:
+		rec := NewMoveEntry(gfr.Version)
+		if err := rec.FromStream(recStream); err != nil {
+			return err
+		}
+		gfr.Name = rec.Name
+		gfr.Record = rec
+	case ENTRYTYPE_FOOTERGAME:
+		rec := &FooterGameEntry{}
+		if err := rec.FromStream(recStream); err != nil {
+			return err
+		}
+		gfr.Name = rec.Name
+		gfr.Record = rec
+	case ENTRYTYPE_FOOTERMATCH:
+		rec := &FooterMatchEntry{}
+		if err := rec.FromStream(recStream); err != nil {
+			return err
+		}
+		gfr.Name = rec.Name
+		gfr.Record = rec
+	case ENTRYTYPE_MISSING:
+		rec := &MissingEntry{}
+		if err := rec.FromStream(recStream); err != nil {
+			return err
+		}
+		gfr.Name = rec.Name
+		gfr.Record = rec
+	default:
+		rec := &UnimplementedEntry{}
+		if err := rec.FromStream(recStream); err != nil {
+			return err
+		}
+		gfr.Name = rec.Name
+		gfr.Record = rec
+	}
+
 	return nil
 }
 
+// MarshalBinary encodes the record padded to XG_GAMEFILEREC_LEN. Version
+// selects the fields written for game, cube and move entries.
+func (gfr *GameFileRecord) MarshalBinary() ([]byte, error) {
+	var packedData []byte
+	var err error
+
+	switch rec := gfr.Record.(type) {
+	case *HeaderMatchEntry:
+		packedData, err = rec.MarshalBinary()
+	case *HeaderGameEntry:
+		packedData, err = rec.marshal(gfr.Version)
+	case *CubeEntry:
+		packedData, err = rec.marshal(gfr.Version)
+	case *MoveEntry:
+		packedData, err = rec.marshal(gfr.Version)
+	case *FooterGameEntry:
+		packedData, err = rec.MarshalBinary()
+	case *FooterMatchEntry:
+		packedData, err = rec.MarshalBinary()
+	case *MissingEntry:
+		packedData, err = rec.MarshalBinary()
+	case *UnimplementedEntry:
+		packedData, err = rec.MarshalBinary()
+	default:
+		err = fmt.Errorf("cannot encode game file record of type %T", gfr.Record)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record := make([]byte, XG_GAMEFILEREC_LEN)
+	copy(record, packedData)
+	return record, nil
+}
+
 type RolloutContextEntry struct {
 	Name                 string
 	EntryType            int32
@@ -534,10 +1445,155 @@ type RolloutContextEntry struct {
 }
 
 func (rce *RolloutContextEntry) FromStream(stream io.Reader) error {
-	// Implement the method based on the Python version
+	var unpackedData [XG_ROLLOUTREC_LEN]byte
+	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
+		return err
+	}
+
+	rce.Name = "Rollout"
+	rce.Truncated = unpackedData[0] != 0
+	rce.ErrorLimited = unpackedData[1] != 0
+	rce.Truncate = int32(binary.LittleEndian.Uint32(unpackedData[4:8]))
+	rce.MinRoll = int32(binary.LittleEndian.Uint32(unpackedData[8:12]))
+	rce.ErrorLimit = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[16:24]))
+	rce.MaxRoll = int32(binary.LittleEndian.Uint32(unpackedData[24:28]))
+	rce.Level1 = int32(binary.LittleEndian.Uint32(unpackedData[28:32]))
+	rce.Level2 = int32(binary.LittleEndian.Uint32(unpackedData[32:36]))
+	rce.LevelCut = int32(binary.LittleEndian.Uint32(unpackedData[36:40]))
+	rce.Variance = unpackedData[40] != 0
+	rce.Cubeless = unpackedData[41] != 0
+	rce.Time = unpackedData[42] != 0
+	rce.Level1C = int32(binary.LittleEndian.Uint32(unpackedData[44:48]))
+	rce.Level2C = int32(binary.LittleEndian.Uint32(unpackedData[48:52]))
+	rce.TimeLimit = int32(binary.LittleEndian.Uint32(unpackedData[52:56]))
+	rce.TruncateBO = int32(binary.LittleEndian.Uint32(unpackedData[56:60]))
+	rce.RandomSeed = int32(binary.LittleEndian.Uint32(unpackedData[60:64]))
+	rce.RandomSeedI = int32(binary.LittleEndian.Uint32(unpackedData[64:68]))
+	rce.RollBoth = unpackedData[68] != 0
+	rce.SearchInterval = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[72:80]))
+	rce.Met = int32(binary.LittleEndian.Uint32(unpackedData[80:84]))
+	rce.FirstRoll = unpackedData[84] != 0
+	rce.DoDouble = unpackedData[85] != 0
+	rce.Extent = unpackedData[86] != 0
+	rce.Rolled = int32(binary.LittleEndian.Uint32(unpackedData[88:92]))
+	rce.DoubleFirst = unpackedData[92] != 0
+
+	// Per-roll statistics, one entry for each of the 37 first rolls.
+	for i := 0; i < 37; i++ {
+		rce.Sum1[i] = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[96+i*8 : 104+i*8]))
+		rce.SumSquare1[i] = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[392+i*8 : 400+i*8]))
+		rce.Sum2[i] = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[688+i*8 : 696+i*8]))
+		rce.SumSquare2[i] = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[984+i*8 : 992+i*8]))
+		rce.Stdev1[i] = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[1280+i*8 : 1288+i*8]))
+		rce.Stdev2[i] = math.Float64frombits(binary.LittleEndian.Uint64(unpackedData[1576+i*8 : 1584+i*8]))
+		rce.RolledD[i] = int32(binary.LittleEndian.Uint32(unpackedData[1872+i*4 : 1876+i*4]))
+	}
+
+	rce.Error1 = float64(math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[2020:2024])))
+	rce.Error2 = float64(math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[2024:2028])))
+	for i := 0; i < 7; i++ {
+		rce.Result1[i] = math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[2028+i*4 : 2032+i*4]))
+		rce.Result2[i] = math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[2056+i*4 : 2060+i*4]))
+	}
+	rce.Mwc1 = float64(math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[2084:2088])))
+	rce.Mwc2 = float64(math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[2088:2092])))
+	rce.PrevLevel = int32(binary.LittleEndian.Uint32(unpackedData[2092:2096]))
+	for i := 0; i < 7; i++ {
+		rce.PrevEval[i] = math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[2096+i*4 : 2100+i*4]))
+	}
+	rce.PrevND = int32(binary.LittleEndian.Uint32(unpackedData[2124:2128]))
+	rce.PrevD = int32(binary.LittleEndian.Uint32(unpackedData[2128:2132]))
+	rce.Duration = int32(binary.LittleEndian.Uint32(unpackedData[2132:2136]))
+	rce.LevelTrunc = int32(binary.LittleEndian.Uint32(unpackedData[2136:2140]))
+	rce.Rolled2 = int32(binary.LittleEndian.Uint32(unpackedData[2140:2144]))
+	rce.MultipleMin = int32(binary.LittleEndian.Uint32(unpackedData[2144:2148]))
+	rce.MultipleStopAll = unpackedData[2148] != 0
+	rce.MultipleStopOne = unpackedData[2149] != 0
+	rce.MultipleStopAllValue = float64(math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[2152:2156])))
+	rce.MultipleStopOneValue = float64(math.Float32frombits(binary.LittleEndian.Uint32(unpackedData[2156:2160])))
+	rce.AsTake = unpackedData[2160] != 0
+	rce.Rotation = int32(binary.LittleEndian.Uint32(unpackedData[2164:2168]))
+	rce.UserInterrupted = unpackedData[2168] != 0
+	rce.VerMaj = int32(binary.LittleEndian.Uint32(unpackedData[2172:2176]))
+	rce.VerMin = int32(binary.LittleEndian.Uint32(unpackedData[2176:2180]))
+
 	return nil
 }
 
+func (rce *RolloutContextEntry) MarshalBinary() ([]byte, error) {
+	packedData := make([]byte, XG_ROLLOUTREC_LEN)
+
+	packedData[0] = boolToByte(rce.Truncated)
+	packedData[1] = boolToByte(rce.ErrorLimited)
+	binary.LittleEndian.PutUint32(packedData[4:8], uint32(rce.Truncate))
+	binary.LittleEndian.PutUint32(packedData[8:12], uint32(rce.MinRoll))
+	binary.LittleEndian.PutUint64(packedData[16:24], math.Float64bits(rce.ErrorLimit))
+	binary.LittleEndian.PutUint32(packedData[24:28], uint32(rce.MaxRoll))
+	binary.LittleEndian.PutUint32(packedData[28:32], uint32(rce.Level1))
+	binary.LittleEndian.PutUint32(packedData[32:36], uint32(rce.Level2))
+	binary.LittleEndian.PutUint32(packedData[36:40], uint32(rce.LevelCut))
+	packedData[40] = boolToByte(rce.Variance)
+	packedData[41] = boolToByte(rce.Cubeless)
+	packedData[42] = boolToByte(rce.Time)
+	binary.LittleEndian.PutUint32(packedData[44:48], uint32(rce.Level1C))
+	binary.LittleEndian.PutUint32(packedData[48:52], uint32(rce.Level2C))
+	binary.LittleEndian.PutUint32(packedData[52:56], uint32(rce.TimeLimit))
+	binary.LittleEndian.PutUint32(packedData[56:60], uint32(rce.TruncateBO))
+	binary.LittleEndian.PutUint32(packedData[60:64], uint32(rce.RandomSeed))
+	binary.LittleEndian.PutUint32(packedData[64:68], uint32(rce.RandomSeedI))
+	packedData[68] = boolToByte(rce.RollBoth)
+	binary.LittleEndian.PutUint64(packedData[72:80], math.Float64bits(rce.SearchInterval))
+	binary.LittleEndian.PutUint32(packedData[80:84], uint32(rce.Met))
+	packedData[84] = boolToByte(rce.FirstRoll)
+	packedData[85] = boolToByte(rce.DoDouble)
+	packedData[86] = boolToByte(rce.Extent)
+	binary.LittleEndian.PutUint32(packedData[88:92], uint32(rce.Rolled))
+	packedData[92] = boolToByte(rce.DoubleFirst)
+
+	for i := 0; i < 37; i++ {
+		binary.LittleEndian.PutUint64(packedData[96+i*8:104+i*8], math.Float64bits(rce.Sum1[i]))
+		binary.LittleEndian.PutUint64(packedData[392+i*8:400+i*8], math.Float64bits(rce.SumSquare1[i]))
+		binary.LittleEndian.PutUint64(packedData[688+i*8:696+i*8], math.Float64bits(rce.Sum2[i]))
+		binary.LittleEndian.PutUint64(packedData[984+i*8:992+i*8], math.Float64bits(rce.SumSquare2[i]))
+		binary.LittleEndian.PutUint64(packedData[1280+i*8:1288+i*8], math.Float64bits(rce.Stdev1[i]))
+		binary.LittleEndian.PutUint64(packedData[1576+i*8:1584+i*8], math.Float64bits(rce.Stdev2[i]))
+		binary.LittleEndian.PutUint32(packedData[1872+i*4:1876+i*4], uint32(rce.RolledD[i]))
+	}
+
+	binary.LittleEndian.PutUint32(packedData[2020:2024], math.Float32bits(float32(rce.Error1)))
+	binary.LittleEndian.PutUint32(packedData[2024:2028], math.Float32bits(float32(rce.Error2)))
+	for i := 0; i < 7; i++ {
+		binary.LittleEndian.PutUint32(packedData[2028+i*4:2032+i*4], math.Float32bits(rce.Result1[i]))
+		binary.LittleEndian.PutUint32(packedData[2056+i*4:2060+i*4], math.Float32bits(rce.Result2[i]))
+	}
+	binary.LittleEndian.PutUint32(packedData[2084:2088], math.Float32bits(float32(rce.Mwc1)))
+	binary.LittleEndian.PutUint32(packedData[2088:2092], math.Float32bits(float32(rce.Mwc2)))
+	binary.LittleEndian.PutUint32(packedData[2092:2096], uint32(rce.PrevLevel))
+	for i := 0; i < 7; i++ {
+		binary.LittleEndian.PutUint32(packedData[2096+i*4:2100+i*4], math.Float32bits(rce.PrevEval[i]))
+	}
+	binary.LittleEndian.PutUint32(packedData[2124:2128], uint32(rce.PrevND))
+	binary.LittleEndian.PutUint32(packedData[2128:2132], uint32(rce.PrevD))
+	binary.LittleEndian.PutUint32(packedData[2132:2136], uint32(rce.Duration))
+	binary.LittleEndian.PutUint32(packedData[2136:2140], uint32(rce.LevelTrunc))
+	binary.LittleEndian.PutUint32(packedData[2140:2144], uint32(rce.Rolled2))
+	binary.LittleEndian.PutUint32(packedData[2144:2148], uint32(rce.MultipleMin))
+	packedData[2148] = boolToByte(rce.MultipleStopAll)
+	packedData[2149] = boolToByte(rce.MultipleStopOne)
+	binary.LittleEndian.PutUint32(packedData[2152:2156], math.Float32bits(float32(rce.MultipleStopAllValue)))
+	binary.LittleEndian.PutUint32(packedData[2156:2160], math.Float32bits(float32(rce.MultipleStopOneValue)))
+	packedData[2160] = boolToByte(rce.AsTake)
+	binary.LittleEndian.PutUint32(packedData[2164:2168], uint32(rce.Rotation))
+	packedData[2168] = boolToByte(rce.UserInterrupted)
+	binary.LittleEndian.PutUint32(packedData[2172:2176], uint32(rce.VerMaj))
+	binary.LittleEndian.PutUint32(packedData[2176:2180], uint32(rce.VerMin))
+
+	return packedData, nil
+}
+
+// XG_ROLLOUTREC_LEN is the fixed size of every record in the rollout file.
+const XG_ROLLOUTREC_LEN = 2184
+
 type RolloutFileRecord struct {
 	Name      string
 	EntryType int32
@@ -545,9 +1601,16 @@ type RolloutFileRecord struct {
 	Version   int32
 }
 
+// FromStream reads one rollout context. io.EOF is returned when the stream
+// holds no more records.
 func (rfr *RolloutFileRecord) FromStream(stream io.Reader) error {
-	// Implement the method based on the Python version
+	if err := rfr.Record.FromStream(stream); err != nil {
+		return err
+	}
+	rfr.Name = rfr.Record.Name
 	return nil
 }
 
-// Define other structures and methods similarly...
+func (rfr *RolloutFileRecord) MarshalBinary() ([]byte, error) {
+	return rfr.Record.MarshalBinary()
+}
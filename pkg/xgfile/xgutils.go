@@ -1,15 +1,18 @@
 package xgfile
 
 import (
-	"bytes"
-	"encoding/binary"
+	"hash/crc32"
 	"io"
+	"math"
 	"time"
+	"unicode/utf16"
 )
 
-// StreamCRC32 computes the CRC32 on a given stream.
+// StreamCRC32 computes the CRC32 on a given stream. It uses the IEEE
+// polynomial, the same checksum as zlib's crc32. numBytes of 0 reads to the
+// end of the stream; the stream position is restored afterwards.
 func StreamCRC32(stream io.ReadSeeker, numBytes int64, startPos int64, blkSize int) (uint32, error) {
-	var crc32 uint32
+	var crc uint32
 	curStreamPos, err := stream.Seek(0, io.SeekCurrent)
 	if err != nil {
 		return 0, err
@@ -27,7 +30,7 @@ func StreamCRC32(stream io.ReadSeeker, numBytes int64, startPos int64, blkSize i
 		for {
 			n, err := stream.Read(buf)
 			if n > 0 {
-				crc32 = crc32Update(crc32, buf[:n])
+				crc = crc32Update(crc, buf[:n])
 			}
 			if err == io.EOF {
 				break
@@ -44,7 +47,7 @@ func StreamCRC32(stream io.ReadSeeker, numBytes int64, startPos int64, blkSize i
 			}
 			n, err := stream.Read(buf[:blkSize])
 			if n > 0 {
-				crc32 = crc32Update(crc32, buf[:n])
+				crc = crc32Update(crc, buf[:n])
 				bytesLeft -= int64(n)
 			}
 			if err != nil {
@@ -57,35 +60,82 @@ func StreamCRC32(stream io.ReadSeeker, numBytes int64, startPos int64, blkSize i
 	if err != nil {
 		return 0, err
 	}
-	return crc32, nil
+	return crc, nil
 }
 
 // crc32Update updates the CRC32 checksum with the given data.
 func crc32Update(crc uint32, data []byte) uint32 {
-	return crc32 ^ binary.LittleEndian.Uint32(data)
+	return crc32.Update(crc, crc32.IEEETable, data)
 }
 
-// UTF16IntArrayToStr converts an array of UTF-16 integers to a string.
+// UTF16IntArrayToStr converts a zero terminated array of UTF-16 integers
+// to a string.
 func UTF16IntArrayToStr(intArray []uint16) string {
-	var buf bytes.Buffer
-	for _, intval := range intArray {
+	for i, intval := range intArray {
 		if intval == 0 {
+			intArray = intArray[:i]
 			break
 		}
-		buf.WriteRune(rune(intval))
 	}
-	return buf.String()
+	return string(utf16.Decode(intArray))
 }
 
-// DelphiDateTimeConv converts a Delphi datetime to a Go time.Time.
+// StrToUTF16IntArray converts a string to an array of size UTF-16
+// integers, zero terminated if it is shorter than size and truncated if it
+// is longer.
+func StrToUTF16IntArray(str string, size int) []uint16 {
+	intArray := make([]uint16, size)
+	encoded := utf16.Encode([]rune(str))
+	if len(encoded) > size {
+		encoded = encoded[:size]
+		// Do not keep half of a surrogate pair.
+		if last := encoded[len(encoded)-1]; utf16.IsSurrogate(rune(last)) && last < 0xdc00 {
+			encoded = encoded[:len(encoded)-1]
+		}
+	}
+	copy(intArray, encoded)
+	return intArray
+}
+
+// delphiEpoch is day 0 of the Delphi TDateTime type.
+var delphiEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// DelphiDateTimeConv converts a Delphi datetime to a Go time.Time, rounded
+// to the second.
 func DelphiDateTimeConv(delphiDatetime float64) time.Time {
 	days := int64(delphiDatetime)
-	seconds := int64((delphiDatetime - float64(days)) * 86400)
-	return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+	seconds := int64(math.Round((delphiDatetime - float64(days)) * 86400))
+	return delphiEpoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+}
+
+// TimeToDelphiDateTime converts a Go time.Time to a Delphi datetime.
+func TimeToDelphiDateTime(t time.Time) float64 {
+	days := math.Floor(t.Sub(delphiEpoch).Hours() / 24)
+	dayStart := delphiEpoch.AddDate(0, 0, int(days))
+	return days + t.Sub(dayStart).Seconds()/86400
 }
 
-// DelphiShortStrToStr converts a Delphi short string to a Go string.
+// DelphiShortStrToStr converts a Delphi short string to a Go string. A
+// length byte larger than the field is clamped to the bytes available.
 func DelphiShortStrToStr(shortStr []byte) string {
+	if len(shortStr) == 0 {
+		return ""
+	}
 	length := int(shortStr[0])
+	if length > len(shortStr)-1 {
+		length = len(shortStr) - 1
+	}
 	return string(shortStr[1 : length+1])
 }
+
+// StrToDelphiShortStr encodes a Go string as a Delphi short string occupying
+// size bytes: a length byte followed by at most size-1 characters.
+func StrToDelphiShortStr(str string, size int) []byte {
+	shortStr := make([]byte, size)
+	length := copy(shortStr[1:], str)
+	if length > 255 {
+		length = 255
+	}
+	shortStr[0] = byte(length)
+	return shortStr
+}
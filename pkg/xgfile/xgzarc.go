@@ -5,16 +5,35 @@ import (
 	"compress/zlib"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
+	"sync"
 )
 
-type Error struct {
-	Value string
+// ChecksumError reports an archive entry or registry whose CRC32 does not
+// match the value stored in the archive. It is the Cause of an Error
+// wrapping ErrChecksum, and matches ErrChecksum itself.
+type ChecksumError struct {
+	Name     string
+	Expected uint32
+	Actual   uint32
 }
 
-func (e *Error) Error() string {
-	return e.Value
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("stored %08x, computed %08x", e.Expected, e.Actual)
+}
+
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksum
+}
+
+func newChecksumError(name string, offset int64, expected, actual uint32) *Error {
+	err := newError(ErrChecksum, 0, &ChecksumError{Name: name, Expected: expected, Actual: actual})
+	err.Segment = name
+	err.Offset = offset
+	return err
 }
 
 type ArchiveRecord struct {
@@ -27,8 +46,38 @@ type ArchiveRecord struct {
 	Reserved           [12]byte
 }
 
+// ARCHIVEREC_LEN is the size of the ArchiveRecord at the end of the archive.
+const ARCHIVEREC_LEN = 36
+
 func (ar *ArchiveRecord) FromStream(stream io.Reader) error {
-	return binary.Read(stream, binary.LittleEndian, ar)
+	var unpackedData [ARCHIVEREC_LEN]byte
+	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
+		return err
+	}
+
+	ar.CRC = binary.LittleEndian.Uint32(unpackedData[0:4])
+	ar.FileCount = int32(binary.LittleEndian.Uint32(unpackedData[4:8]))
+	ar.Version = int32(binary.LittleEndian.Uint32(unpackedData[8:12]))
+	ar.RegistrySize = int32(binary.LittleEndian.Uint32(unpackedData[12:16]))
+	ar.ArchiveSize = int32(binary.LittleEndian.Uint32(unpackedData[16:20]))
+	ar.CompressedRegistry = binary.LittleEndian.Uint32(unpackedData[20:24]) != 0
+	copy(ar.Reserved[:], unpackedData[24:36])
+
+	return nil
+}
+
+func (ar *ArchiveRecord) MarshalBinary() ([]byte, error) {
+	packedData := make([]byte, ARCHIVEREC_LEN)
+	binary.LittleEndian.PutUint32(packedData[0:4], ar.CRC)
+	binary.LittleEndian.PutUint32(packedData[4:8], uint32(ar.FileCount))
+	binary.LittleEndian.PutUint32(packedData[8:12], uint32(ar.Version))
+	binary.LittleEndian.PutUint32(packedData[12:16], uint32(ar.RegistrySize))
+	binary.LittleEndian.PutUint32(packedData[16:20], uint32(ar.ArchiveSize))
+	if ar.CompressedRegistry {
+		binary.LittleEndian.PutUint32(packedData[20:24], 1)
+	}
+	copy(packedData[24:36], ar.Reserved[:])
+	return packedData, nil
 }
 
 type FileRecord struct {
@@ -42,18 +91,48 @@ type FileRecord struct {
 	CompressionLevel byte
 }
 
+// FILEREC_LEN is the size of one FileRecord in the archive registry.
+const FILEREC_LEN = 532
+
 func (fr *FileRecord) FromStream(stream io.Reader) error {
-	var nameBytes [256]byte
-	var pathBytes [256]byte
-	if err := binary.Read(stream, binary.LittleEndian, &nameBytes); err != nil {
+	var unpackedData [FILEREC_LEN]byte
+	if _, err := io.ReadFull(stream, unpackedData[:]); err != nil {
 		return err
 	}
-	if err := binary.Read(stream, binary.LittleEndian, &pathBytes); err != nil {
-		return err
+
+	fr.Name = DelphiShortStrToStr(unpackedData[0:256])
+	fr.Path = DelphiShortStrToStr(unpackedData[256:512])
+	fr.OSize = int32(binary.LittleEndian.Uint32(unpackedData[512:516]))
+	fr.CSize = int32(binary.LittleEndian.Uint32(unpackedData[516:520]))
+	fr.Start = int32(binary.LittleEndian.Uint32(unpackedData[520:524]))
+	fr.CRC = binary.LittleEndian.Uint32(unpackedData[524:528])
+	// The flag byte is set for entries that are stored uncompressed.
+	fr.Compressed = unpackedData[528] == 0
+	fr.CompressionLevel = unpackedData[529]
+
+	if fr.OSize < 0 || fr.CSize < 0 || fr.Start < 0 {
+		return newError(ErrCorrupt, 512, fmt.Errorf("sizes %d/%d at %d", fr.OSize, fr.CSize, fr.Start))
+	}
+
+	return nil
+}
+
+func (fr *FileRecord) MarshalBinary() ([]byte, error) {
+	if len(fr.Name) > 255 || len(fr.Path) > 255 {
+		return nil, errors.New("file record name or path longer than 255 bytes")
+	}
+	packedData := make([]byte, FILEREC_LEN)
+	copy(packedData[0:256], StrToDelphiShortStr(fr.Name, 256))
+	copy(packedData[256:512], StrToDelphiShortStr(fr.Path, 256))
+	binary.LittleEndian.PutUint32(packedData[512:516], uint32(fr.OSize))
+	binary.LittleEndian.PutUint32(packedData[516:520], uint32(fr.CSize))
+	binary.LittleEndian.PutUint32(packedData[520:524], uint32(fr.Start))
+	binary.LittleEndian.PutUint32(packedData[524:528], fr.CRC)
+	if !fr.Compressed {
+		packedData[528] = 1
 	}
-	fr.Name = string(bytes.Trim(nameBytes[:], "\x00"))
-	fr.Path = string(bytes.Trim(pathBytes[:], "\x00"))
-	return binary.Read(stream, binary.LittleEndian, fr)
+	packedData[529] = fr.CompressionLevel
+	return packedData, nil
 }
 
 type ZlibArchive struct {
@@ -62,8 +141,27 @@ type ZlibArchive struct {
 	StartOfArcData int64
 	EndOfArcData   int64
 	Filename       string
-	Stream         *os.File
+	Stream         SegmentReader
 	MaxBufSize     int
+	// ChecksumErrors lists a *ChecksumError for the registry and for each
+	// entry read whose CRC32 does not match the stored one. Mismatches do
+	// not stop the archive from being read: the checks assume the stored
+	// CRC covers the decompressed data, which has only been confirmed on
+	// archives ZlibArchiveWriter wrote.
+	ChecksumErrors []error
+
+	checksumMu sync.Mutex
+}
+
+// checkCRC appends a checksum error for name to ChecksumErrors when crc
+// differs from the expected one.
+func (za *ZlibArchive) checkCRC(name string, expected, crc uint32) {
+	if crc == expected {
+		return
+	}
+	za.checksumMu.Lock()
+	za.ChecksumErrors = append(za.ChecksumErrors, newChecksumError(name, 0, expected, crc))
+	za.checksumMu.Unlock()
 }
 
 func NewZlibArchive(filename string) (*ZlibArchive, error) {
@@ -71,8 +169,23 @@ func NewZlibArchive(filename string) (*ZlibArchive, error) {
 	if err != nil {
 		return nil, err
 	}
+	za, err := newZlibArchive(stream)
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+	za.Filename = filename
+	return za, nil
+}
+
+// NewZlibArchiveReaderAt reads the archive held in the first size bytes
+// of r.
+func NewZlibArchiveReaderAt(r io.ReaderAt, size int64) (*ZlibArchive, error) {
+	return newZlibArchive(io.NewSectionReader(r, 0, size))
+}
+
+func newZlibArchive(stream SegmentReader) (*ZlibArchive, error) {
 	za := &ZlibArchive{
-		Filename:   filename,
 		Stream:     stream,
 		MaxBufSize: 32768,
 	}
@@ -82,33 +195,77 @@ func NewZlibArchive(filename string) (*ZlibArchive, error) {
 	return za, nil
 }
 
-func (za *ZlibArchive) extractSegment(isCompressed bool, numBytes int) (string, error) {
+// Close closes the underlying stream if the archive opened it.
+func (za *ZlibArchive) Close() error {
+	if closer, ok := za.Stream.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// maxZlibRatio bounds the size of inflated data: deflate never expands its
+// input by more than this factor.
+const maxZlibRatio = 1032
+
+// readSegment reads numBytes at the current stream position into memory,
+// inflating them if they are compressed. Inflated data is cut at maxSize
+// bytes.
+func (za *ZlibArchive) readSegment(isCompressed bool, numBytes int, maxSize int64) ([]byte, error) {
+	var buf bytes.Buffer
+	src := io.LimitReader(za.Stream, int64(numBytes))
+	if isCompressed {
+		decomp, err := zlib.NewReader(src)
+		if err != nil {
+			return nil, newError(ErrDecompress, 0, err)
+		}
+		defer decomp.Close()
+		if n, err := io.Copy(&buf, io.LimitReader(decomp, maxSize)); err != nil {
+			return nil, newError(ErrDecompress, n, err)
+		}
+	} else {
+		if n, err := io.CopyN(&buf, src, int64(numBytes)); err != nil {
+			return nil, newError(ErrTruncated, n, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// extractSegment is readSegment writing to a temporary file, whose name is
+// returned.
+func (za *ZlibArchive) extractSegment(isCompressed bool, numBytes int, maxSize int64) (filename string, err error) {
 	tmpFile, err := os.CreateTemp("", "tmpXGI")
 	if err != nil {
 		return "", err
 	}
-	defer tmpFile.Close()
+	defer func() {
+		tmpFile.Close()
+		if err != nil {
+			os.Remove(tmpFile.Name())
+		}
+	}()
 
+	src := io.LimitReader(za.Stream, int64(numBytes))
 	if isCompressed {
-		decomp, err := zlib.NewReader(za.Stream)
+		decomp, err := zlib.NewReader(src)
 		if err != nil {
-			return "", err
+			return "", newError(ErrDecompress, 0, err)
 		}
 		defer decomp.Close()
-		if _, err := io.Copy(tmpFile, decomp); err != nil {
-			return "", err
+		if n, err := io.Copy(tmpFile, io.LimitReader(decomp, maxSize)); err != nil {
+			return "", newError(ErrDecompress, n, err)
 		}
 	} else {
-		if numBytes <= 0 {
-			return "", errors.New("invalid number of bytes for uncompressed segment")
-		}
-		if _, err := io.CopyN(tmpFile, za.Stream, int64(numBytes)); err != nil {
-			return "", err
+		if n, err := io.CopyN(tmpFile, src, int64(numBytes)); err != nil {
+			return "", newError(ErrTruncated, n, err)
 		}
 	}
 	return tmpFile.Name(), nil
 }
 
+// getArchiveIndex reads the ArchiveRecord and the registry. The sizes and
+// counts it stores are checked against the stream before they are used,
+// since they come from the file. Offsets of errors in the "archive"
+// segment are stream offsets.
 func (za *ZlibArchive) getArchiveIndex() error {
 	curStreamPos, err := za.Stream.Seek(0, io.SeekCurrent)
 	if err != nil {
@@ -116,57 +273,133 @@ func (za *ZlibArchive) getArchiveIndex() error {
 	}
 	defer za.Stream.Seek(curStreamPos, io.SeekStart)
 
-	if _, err := za.Stream.Seek(-int64(binary.Size(ArchiveRecord{})), io.SeekEnd); err != nil {
+	size, err := za.Stream.Seek(0, io.SeekEnd)
+	if err != nil {
 		return err
 	}
-	za.EndOfArcData, _ = za.Stream.Seek(0, io.SeekCurrent)
-	if err := za.ArcRec.FromStream(za.Stream); err != nil {
+	if size < ARCHIVEREC_LEN {
+		return withContext(newError(ErrTruncated, 0, nil), "archive", 0, -1)
+	}
+	za.EndOfArcData, err = za.Stream.Seek(-ARCHIVEREC_LEN, io.SeekEnd)
+	if err != nil {
 		return err
 	}
+	if err := za.ArcRec.FromStream(za.Stream); err != nil {
+		return withContext(err, "archive", za.EndOfArcData, -1)
+	}
 
-	if _, err := za.Stream.Seek(-int64(binary.Size(ArchiveRecord{}))-int64(za.ArcRec.RegistrySize), io.SeekEnd); err != nil {
-		return err
+	if za.ArcRec.RegistrySize <= 0 || int64(za.ArcRec.RegistrySize) > za.EndOfArcData {
+		return withContext(newError(ErrCorrupt, 12, fmt.Errorf("registry size %d", za.ArcRec.RegistrySize)),
+			"archive", za.EndOfArcData, -1)
+	}
+	startOfRegistry := za.EndOfArcData - int64(za.ArcRec.RegistrySize)
+	if za.ArcRec.ArchiveSize < 0 || int64(za.ArcRec.ArchiveSize) > startOfRegistry {
+		return withContext(newError(ErrCorrupt, 16, fmt.Errorf("archive size %d", za.ArcRec.ArchiveSize)),
+			"archive", za.EndOfArcData, -1)
 	}
-	za.StartOfArcData, _ = za.Stream.Seek(0, io.SeekCurrent)
-	za.StartOfArcData -= int64(za.ArcRec.ArchiveSize)
+	za.StartOfArcData = startOfRegistry - int64(za.ArcRec.ArchiveSize)
 
-	idxFilename, err := za.extractSegment(za.ArcRec.CompressedRegistry, 0)
-	if err != nil {
-		return err
+	registrySize := int64(za.ArcRec.FileCount) * FILEREC_LEN
+	maxRegistrySize := int64(za.ArcRec.RegistrySize)
+	if za.ArcRec.CompressedRegistry {
+		maxRegistrySize *= maxZlibRatio
+	}
+	if za.ArcRec.FileCount < 0 || registrySize > maxRegistrySize {
+		return withContext(newError(ErrCorrupt, 4, fmt.Errorf("file count %d", za.ArcRec.FileCount)),
+			"archive", za.EndOfArcData, -1)
 	}
-	defer os.Remove(idxFilename)
 
-	idxFile, err := os.Open(idxFilename)
-	if err != nil {
+	if _, err := za.Stream.Seek(startOfRegistry, io.SeekStart); err != nil {
 		return err
 	}
-	defer idxFile.Close()
+	idxData, err := za.readSegment(za.ArcRec.CompressedRegistry, int(za.ArcRec.RegistrySize), registrySize)
+	if err != nil {
+		return withContext(err, "archive registry", 0, -1)
+	}
+	za.checkCRC("archive registry", za.ArcRec.CRC, crc32.ChecksumIEEE(idxData))
+	idxStream := bytes.NewReader(idxData)
 
 	for i := 0; i < int(za.ArcRec.FileCount); i++ {
 		var filerec FileRecord
-		if err := filerec.FromStream(idxFile); err != nil {
-			return err
+		if err := filerec.FromStream(idxStream); err != nil {
+			return withContext(err, "archive registry", int64(i)*FILEREC_LEN, i)
 		}
 		za.ArcRegistry = append(za.ArcRegistry, filerec)
 	}
 	return nil
 }
 
+// entryStart returns the stream offset of an entry after checking that
+// its data lies within the archive.
+func (za *ZlibArchive) entryStart(filerec FileRecord) (int64, error) {
+	start := za.StartOfArcData + int64(filerec.Start)
+	if filerec.Start < 0 || filerec.CSize < 0 ||
+		start+int64(filerec.CSize) > za.StartOfArcData+int64(za.ArcRec.ArchiveSize) {
+		cause := fmt.Errorf("entry data at %d, %d bytes, lies outside the archive data", filerec.Start, filerec.CSize)
+		return 0, withContext(newError(ErrCorrupt, 0, cause), filerec.Name, 0, -1)
+	}
+	return start, nil
+}
+
 func (za *ZlibArchive) GetArchiveFile(filerec FileRecord) (*os.File, error) {
-	if _, err := za.Stream.Seek(int64(filerec.Start)+za.StartOfArcData, io.SeekStart); err != nil {
+	start, err := za.entryStart(filerec)
+	if err != nil {
 		return nil, err
 	}
-	tmpFilename, err := za.extractSegment(filerec.Compressed, int(filerec.CSize))
-	if err != nil {
+	if _, err := za.Stream.Seek(start, io.SeekStart); err != nil {
 		return nil, err
 	}
+	tmpFilename, err := za.extractSegment(filerec.Compressed, int(filerec.CSize), int64(filerec.OSize))
+	if err != nil {
+		return nil, withContext(err, filerec.Name, 0, -1)
+	}
 	tmpFile, err := os.Open(tmpFilename)
 	if err != nil {
+		os.Remove(tmpFilename)
 		return nil, err
 	}
+	crc, err := StreamCRC32(tmpFile, 0, 0, za.MaxBufSize)
+	if err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFilename)
+		return nil, err
+	}
+	za.checkCRC(filerec.Name, filerec.CRC, crc)
 	return tmpFile, nil
 }
 
+// OpenArchiveFile returns the contents of an archive entry without going
+// through a temporary file. Compressed entries are inflated into memory,
+// stored entries are returned as a view on the archive stream. The archive
+// stream is only accessed through ReadAt, so entries may be opened
+// concurrently.
+func (za *ZlibArchive) OpenArchiveFile(filerec FileRecord) (SegmentReader, error) {
+	start, err := za.entryStart(filerec)
+	if err != nil {
+		return nil, err
+	}
+	section := io.NewSectionReader(za.Stream, start, int64(filerec.CSize))
+	if !filerec.Compressed {
+		crc, err := StreamCRC32(section, 0, 0, za.MaxBufSize)
+		if err != nil {
+			return nil, err
+		}
+		za.checkCRC(filerec.Name, filerec.CRC, crc)
+		return section, nil
+	}
+	decomp, err := zlib.NewReader(section)
+	if err != nil {
+		return nil, withContext(newError(ErrDecompress, 0, err), filerec.Name, 0, -1)
+	}
+	defer decomp.Close()
+	data, err := io.ReadAll(io.LimitReader(decomp, int64(filerec.OSize)))
+	if err != nil {
+		return nil, withContext(newError(ErrDecompress, int64(len(data)), err), filerec.Name, 0, -1)
+	}
+	za.checkCRC(filerec.Name, filerec.CRC, crc32.ChecksumIEEE(data))
+	return bytes.NewReader(data), nil
+}
+
 func (za *ZlibArchive) SetBlockSize(blksize int) {
 	za.MaxBufSize = blksize
 }
@@ -0,0 +1,88 @@
+package xgfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+// Checksum mismatches are only reported until the CRC convention has
+// been checked against archives written by ExtremeGammon, so the registry
+// and the entries are treated alike.
+func TestChecksumMismatchNotFatal(t *testing.T) {
+	data := testExportBytes(t, 28, 0)
+	crcOffset := len(data) - ARCHIVEREC_LEN
+	crc := binary.LittleEndian.Uint32(data[crcOffset:])
+	binary.LittleEndian.PutUint32(data[crcOffset:], crc^1)
+	// Damage the stored comments as well.
+	i := bytes.Index(data, []byte("Hello"))
+	if i < 0 {
+		t.Fatal("comment not found in the export")
+	}
+	data[i] = 'J'
+
+	for _, inMemory := range []bool{true, false} {
+		imp := NewImportReaderAt(bytes.NewReader(data), int64(len(data)))
+		imp.InMemory = inMemory
+		segments, err := imp.GetFileSegment()
+		if err != nil {
+			t.Fatalf("InMemory %v: %v", inMemory, err)
+		}
+		var names []string
+		for _, diag := range imp.Diagnostics {
+			var checksumErr *ChecksumError
+			if !errors.As(diag, &checksumErr) || !errors.Is(diag, ErrChecksum) {
+				t.Errorf("InMemory %v: diagnostic %v is not a checksum error", inMemory, diag)
+				continue
+			}
+			names = append(names, checksumErr.Name)
+		}
+		if len(names) != 2 || names[0] != "archive registry" || names[1] != "temp.xgc" {
+			t.Errorf("InMemory %v: checksum errors for %q, want the registry and temp.xgc", inMemory, names)
+		}
+		for _, segment := range segments {
+			if segment.Type == XG_COMMENT {
+				cf, err := segment.CommentFile()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if c := cf.Comment(0); c == nil || c.Text != "Jello" {
+					t.Errorf("InMemory %v: comment %v, want the damaged text", inMemory, c)
+				}
+			}
+			segment.Close()
+		}
+	}
+}
+
+func TestZlibArchiveFS(t *testing.T) {
+	var buf bytes.Buffer
+	zw := NewZlibArchiveWriter(&buf)
+	for i, name := range []string{"temp.xgi", "temp.xg", "temp.xgr", "temp.xgc", "thumb.jpg"} {
+		data := bytes.Repeat([]byte(name), 100*i)
+		if err := zw.AddFile(name, data, 6*(i%2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	za, err := NewZlibArchiveReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(za, "temp.xgi", "temp.xg", "temp.xgr", "temp.xgc", "thumb.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Registry paths become directories, with Windows separators and
+	// drive letters dropped.
+	za.ArcRegistry[1].Path = `C:\games\xg`
+	za.ArcRegistry[4].Path = "games"
+	if err := fstest.TestFS(za, "temp.xgi", "games/xg/temp.xg", "games/thumb.jpg"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,179 @@
+package xgfile
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ZlibArchive implements fs.FS and fs.ReadDirFS. Each FileRecord of the
+// registry is a file at the slash-separated join of its Path and Name.
+var (
+	_ fs.FS        = (*ZlibArchive)(nil)
+	_ fs.ReadDirFS = (*ZlibArchive)(nil)
+)
+
+// ArchiveFileInfo describes an archive entry or directory. Sys returns the
+// FileRecord of entries, which carries the compression state and CRC.
+type ArchiveFileInfo struct {
+	name   string
+	isDir  bool
+	record FileRecord
+}
+
+func (fi *ArchiveFileInfo) Name() string { return fi.name }
+
+// Size returns the uncompressed size of the entry.
+func (fi *ArchiveFileInfo) Size() int64 {
+	if fi.isDir {
+		return 0
+	}
+	return int64(fi.record.OSize)
+}
+
+func (fi *ArchiveFileInfo) Mode() fs.FileMode {
+	if fi.isDir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// ModTime returns the zero time; the archive does not store timestamps.
+func (fi *ArchiveFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi *ArchiveFileInfo) IsDir() bool        { return fi.isDir }
+func (fi *ArchiveFileInfo) Sys() interface{}   { return fi.record }
+
+// Compressed reports whether the entry is stored zlib compressed.
+func (fi *ArchiveFileInfo) Compressed() bool { return fi.record.Compressed }
+
+// CRC returns the CRC32 recorded for the entry.
+func (fi *ArchiveFileInfo) CRC() uint32 { return fi.record.CRC }
+
+func (fi *ArchiveFileInfo) Type() fs.FileMode          { return fi.Mode().Type() }
+func (fi *ArchiveFileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+func (fi *ArchiveFileInfo) String() string             { return fs.FormatFileInfo(fi) }
+
+// archivePath returns the fs path of a registry entry.
+func archivePath(filerec FileRecord) string {
+	dir := strings.Trim(strings.ReplaceAll(filerec.Path, "\\", "/"), "/")
+	if i := strings.Index(dir, ":"); i >= 0 {
+		// Drop a drive letter.
+		dir = strings.TrimLeft(dir[i+1:], "/")
+	}
+	return path.Clean(path.Join(dir, filerec.Name))
+}
+
+// Open opens the archive entry or directory with the given name.
+func (za *ZlibArchive) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	for _, filerec := range za.ArcRegistry {
+		if archivePath(filerec) == name {
+			reader, err := za.OpenArchiveFile(filerec)
+			if err != nil {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+			}
+			return &archiveFile{
+				SegmentReader: reader,
+				info:          &ArchiveFileInfo{name: path.Base(name), record: filerec},
+			}, nil
+		}
+	}
+	entries, err := za.ReadDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &archiveDir{
+		info:    &ArchiveFileInfo{name: path.Base(name), isDir: true},
+		entries: entries,
+	}, nil
+}
+
+// ReadDir lists the entries and subdirectories of the named directory,
+// sorted by name.
+func (za *ZlibArchive) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	found := name == "."
+	seen := map[string]bool{}
+	var entries []fs.DirEntry
+	for _, filerec := range za.ArcRegistry {
+		rel := archivePath(filerec)
+		if name != "." {
+			if !strings.HasPrefix(rel, name+"/") {
+				if rel == name {
+					return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+				}
+				continue
+			}
+			rel = rel[len(name)+1:]
+		}
+		found = true
+
+		if i := strings.Index(rel, "/"); i >= 0 {
+			dir := rel[:i]
+			if !seen[dir] {
+				seen[dir] = true
+				entries = append(entries, &ArchiveFileInfo{name: dir, isDir: true})
+			}
+			continue
+		}
+		if !seen[rel] {
+			seen[rel] = true
+			entries = append(entries, &ArchiveFileInfo{name: rel, record: filerec})
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// archiveFile is an open archive entry. It supports Seek and ReadAt so it
+// can be served by http.FileServer.
+type archiveFile struct {
+	SegmentReader
+	info *ArchiveFileInfo
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *archiveFile) Close() error               { return nil }
+
+// archiveDir is an open archive directory.
+type archiveDir struct {
+	info    *ArchiveFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *archiveDir) Close() error               { return nil }
+
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *archiveDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	d.offset += count
+	return remaining[:count], nil
+}
@@ -0,0 +1,137 @@
+package xgfile
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// ZLIBARC_VERSION is the archive version written by ZlibArchiveWriter.
+const ZLIBARC_VERSION = 1
+
+// ZlibArchiveWriter writes a zlib archive readable by NewZlibArchive: the
+// entry data, the registry of FileRecords and the trailing ArchiveRecord.
+// The archive may follow other data in the same stream, as it does in .xg
+// files, since entry offsets are relative to the start of the archive.
+type ZlibArchiveWriter struct {
+	// CompressRegistry stores the registry zlib compressed.
+	CompressRegistry bool
+
+	stream   io.Writer
+	registry []FileRecord
+	size     int64
+	closed   bool
+}
+
+func NewZlibArchiveWriter(stream io.Writer) *ZlibArchiveWriter {
+	return &ZlibArchiveWriter{stream: stream, CompressRegistry: true}
+}
+
+// AddFile appends an entry to the archive. A compressionLevel of 0 stores
+// the data as is; 1 to 9 compress it with the matching zlib level.
+func (zw *ZlibArchiveWriter) AddFile(name string, data []byte, compressionLevel int) error {
+	if zw.closed {
+		return errors.New("archive writer is closed")
+	}
+	if compressionLevel < 0 || compressionLevel > zlib.BestCompression {
+		return errors.New("invalid compression level")
+	}
+
+	filerec := FileRecord{
+		Name:             name,
+		OSize:            int32(len(data)),
+		Start:            int32(zw.size),
+		CRC:              crc32.ChecksumIEEE(data),
+		Compressed:       compressionLevel > 0,
+		CompressionLevel: byte(compressionLevel),
+	}
+
+	stored := data
+	if filerec.Compressed {
+		compressed, err := zlibCompress(data, compressionLevel)
+		if err != nil {
+			return err
+		}
+		stored = compressed
+	}
+	filerec.CSize = int32(len(stored))
+
+	// Validate the record before any entry data reaches the stream.
+	if _, err := filerec.MarshalBinary(); err != nil {
+		return err
+	}
+	if _, err := zw.stream.Write(stored); err != nil {
+		return err
+	}
+	zw.size += int64(len(stored))
+	zw.registry = append(zw.registry, filerec)
+	return nil
+}
+
+// Registry returns the FileRecords of the entries added so far.
+func (zw *ZlibArchiveWriter) Registry() []FileRecord {
+	return zw.registry
+}
+
+// Close writes the registry and the ArchiveRecord. It does not close the
+// underlying stream.
+func (zw *ZlibArchiveWriter) Close() error {
+	if zw.closed {
+		return nil
+	}
+	zw.closed = true
+
+	var registry bytes.Buffer
+	for i := range zw.registry {
+		packedData, err := zw.registry[i].MarshalBinary()
+		if err != nil {
+			return err
+		}
+		registry.Write(packedData)
+	}
+
+	arcrec := ArchiveRecord{
+		CRC:                crc32.ChecksumIEEE(registry.Bytes()),
+		FileCount:          int32(len(zw.registry)),
+		Version:            ZLIBARC_VERSION,
+		ArchiveSize:        int32(zw.size),
+		CompressedRegistry: zw.CompressRegistry,
+	}
+
+	stored := registry.Bytes()
+	if zw.CompressRegistry {
+		compressed, err := zlibCompress(stored, zlib.DefaultCompression)
+		if err != nil {
+			return err
+		}
+		stored = compressed
+	}
+	arcrec.RegistrySize = int32(len(stored))
+
+	if _, err := zw.stream.Write(stored); err != nil {
+		return err
+	}
+	packedData, err := arcrec.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = zw.stream.Write(packedData)
+	return err
+}
+
+func zlibCompress(data []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	comp, err := zlib.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := comp.Write(data); err != nil {
+		return nil, err
+	}
+	if err := comp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}